@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// APIPrefix is the path prefix of the versioned REST API
+const APIPrefix = "/api/v1"
+
+// maxPageSize bounds the limit query parameter of paginated endpoints
+const maxPageSize = 1000
+
+// apiErrorCodes maps HTTP statuses to the machine-readable codes of the error envelope
+var apiErrorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusMethodNotAllowed:    "method_not_allowed",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusServiceUnavailable:  "unavailable",
+}
+
+// APIError describes why an API request failed
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIErrorResponse is the envelope of every error returned under /api/v1
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// APIJob summarizes a scrape job and the targets currently served for it
+type APIJob struct {
+	Name           string `json:"name"`
+	MetricsPath    string `json:"metrics_path"`
+	ScrapeInterval string `json:"scrape_interval"`
+	ScrapeTimeout  string `json:"scrape_timeout"`
+	Scheme         string `json:"scheme"`
+	TargetGroups   int    `json:"target_groups"`
+	Targets        int    `json:"targets"`
+}
+
+// APITargetGroup is a target group served on /sd, tagged with its job
+type APITargetGroup struct {
+	Job     string            `json:"job"`
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// APIParam documents a path or query parameter of an API route
+type APIParam struct {
+	Name        string
+	In          string // path or query
+	Description string
+	Required    bool
+	Type        string // OpenAPI type of the value; string if empty
+}
+
+// APIRoute describes an API endpoint. The same table registers the routes and generates
+// the OpenAPI document, so the two cannot drift apart.
+type APIRoute struct {
+	Method    string
+	Path      string
+	Summary   string
+	Params    []APIParam
+	Request   any  // Example value of the request body type; nil if the route takes no body
+	Response  any  // Example value of the response body type
+	Paginated bool // Accepts limit and offset and sets X-Total-Count and Link headers
+	CSV       bool // Also answers in CSV with ?format=csv
+	Handler   http.HandlerFunc
+}
+
+// apiRoutes returns the routes of the versioned REST API
+func (m *MinIOClient) apiRoutes() []APIRoute {
+	return []APIRoute{
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/jobs",
+			Summary:  "List scrape jobs with their settings and current target counts",
+			Response: []APIJob{}, Handler: m.handleAPIJobs,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/jobs/{job}",
+			Summary:  "Get a scrape job",
+			Params:   []APIParam{{Name: "job", In: "path", Required: true, Description: "Job name"}},
+			Response: APIJob{}, Handler: m.handleAPIJob,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/targets",
+			Summary:  "List the target groups served on /sd",
+			Params:   []APIParam{{Name: "job", In: "query", Description: "Only return target groups of this job"}},
+			Response: []APITargetGroup{}, Paginated: true, Handler: m.handleAPITargets,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/clusters",
+			Summary:  "List clusters with their discovery state",
+			Response: []ClusterSummary{}, Handler: m.handleAPIClusters,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/clusters/{cluster}",
+			Summary:  "Get the discovery state of a cluster",
+			Params:   []APIParam{{Name: "cluster", In: "path", Required: true, Description: "Cluster name"}},
+			Response: ClusterSummary{}, Handler: m.handleAPICluster,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/nodes",
+			Summary:  "List discovered MinIO nodes",
+			Response: []NodeInventoryEntry{}, Paginated: true, CSV: true, Handler: m.handleNodes,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/buckets",
+			Summary:  "List discovered buckets with their include/exclude decision",
+			Response: []BucketInventoryEntry{}, Paginated: true, CSV: true, Handler: m.handleBuckets,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/changes",
+			Summary:  "List recent node and bucket changes, newest first",
+			Response: []ChangeEvent{}, Paginated: true, Handler: m.handleAPIChanges,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/config",
+			Summary:  "Get the effective configuration and the source of each setting, with credentials masked",
+			Response: []ConfigSetting{}, Handler: m.handleAPIConfig,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/reload",
+			Summary:  "Get the outcome of the last configuration load or reload",
+			Response: ReloadStatus{}, Handler: m.handleAPIReload,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/shards",
+			Summary:  "Show how target groups are distributed across hashmod shards",
+			Params:   []APIParam{{Name: ShardsParam, In: "query", Required: true, Type: "integer", Description: "Number of shards"}},
+			Response: ShardsResponse{}, Handler: m.handleShards,
+		},
+		{
+			Method: http.MethodPost, Path: APIPrefix + "/preview",
+			Summary: "Preview the effect of a candidate config fragment (YAML or JSON) on the served target groups",
+			Request: ConfigFragment{}, Response: PreviewResponse{}, Handler: m.handlePreview,
+		},
+		{
+			Method: http.MethodGet, Path: APIPrefix + "/openapi.json",
+			Summary:  "Get this OpenAPI document",
+			Response: map[string]any{}, Handler: m.handleOpenAPI,
+		},
+	}
+}
+
+// RegisterAPIRoutes adds the versioned REST API to a router. Unknown paths and methods
+// under the API prefix are answered with the JSON error envelope.
+func (m *MinIOClient) RegisterAPIRoutes(router *mux.Router) {
+	for _, route := range m.apiRoutes() {
+		logrus.Infof("  %s %s - %s", route.Method, route.Path, route.Summary)
+		router.HandleFunc(route.Path, route.Handler).Methods(route.Method)
+	}
+	router.NotFoundHandler = apiFallback(http.StatusNotFound, http.NotFoundHandler())
+	router.MethodNotAllowedHandler = apiFallback(http.StatusMethodNotAllowed, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+}
+
+// apiFallback answers requests under the API prefix with the error envelope and passes other requests to next
+func apiFallback(status int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, APIPrefix+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeAPIError(w, status, "%s %s: %s", r.Method, r.URL.Path, strings.ToLower(http.StatusText(status)))
+	})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes the JSON error envelope
+func writeAPIError(w http.ResponseWriter, status int, format string, args ...any) {
+	code, ok := apiErrorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	writeJSON(w, status, APIErrorResponse{Error: APIError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+// paginate returns the page of items selected by the limit and offset query parameters
+// and sets the X-Total-Count header, plus a Link header to the next page if there is one.
+// Without limit, all items from offset on are returned. It writes a 400 error and returns
+// false when the parameters are invalid.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) ([]T, bool) {
+	query := r.URL.Query()
+	offset, limit := 0, 0
+	if value := query.Get("offset"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid offset %q", value)
+			return nil, false
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			writeAPIError(w, http.StatusBadRequest, "invalid limit %q, expected 1 to %d", value, maxPageSize)
+			return nil, false
+		}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	start := min(offset, len(items))
+	end := len(items)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+		next := url.Values{}
+		for name, values := range query {
+			next[name] = values
+		}
+		next.Set("offset", strconv.Itoa(end))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	return items[start:end], true
+}
+
+// apiJob summarizes a job from its scrape config and target groups
+func apiJob(config ScrapeConfig, groups []ServiceDiscoveryResponse) APIJob {
+	job := APIJob{
+		Name:           config.JobName,
+		MetricsPath:    config.MetricsPath,
+		ScrapeInterval: config.ScrapeInterval,
+		ScrapeTimeout:  config.ScrapeTimeout,
+		Scheme:         config.Scheme,
+		TargetGroups:   len(groups),
+	}
+	for _, group := range groups {
+		job.Targets += len(group.Targets)
+	}
+	return job
+}
+
+// jobState returns the scrape configs of every job and the current snapshot to evaluate them against
+func (m *MinIOClient) jobState(r *http.Request) ([]ScrapeConfig, *Snapshot, error) {
+	configs, err := m.GenerateScrapeConfigs(r.Context())
+	if err != nil {
+		logrus.Errorf("Failed to generate scrape configs: %v", err)
+		return nil, nil, err
+	}
+	return configs, m.Discover(r.Context()), nil
+}
+
+// handleAPIJobs handles GET /api/v1/jobs
+func (m *MinIOClient) handleAPIJobs(w http.ResponseWriter, r *http.Request) {
+	configs, snapshot, err := m.jobState(r)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to generate scrape configs")
+		return
+	}
+	jobs := make([]APIJob, 0, len(configs))
+	for _, config := range configs {
+		jobs = append(jobs, apiJob(config, m.buildTargetGroups(config, snapshot, m.bucketFilter())))
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// handleAPIJob handles GET /api/v1/jobs/{job}
+func (m *MinIOClient) handleAPIJob(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["job"]
+	configs, snapshot, err := m.jobState(r)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to generate scrape configs")
+		return
+	}
+	for _, config := range configs {
+		if config.JobName == name {
+			writeJSON(w, http.StatusOK, apiJob(config, m.buildTargetGroups(config, snapshot, m.bucketFilter())))
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "job %q not found", name)
+}
+
+// handleAPITargets handles GET /api/v1/targets, optionally restricted to one job with ?job=
+func (m *MinIOClient) handleAPITargets(w http.ResponseWriter, r *http.Request) {
+	job := r.URL.Query().Get("job")
+	configs, snapshot, err := m.jobState(r)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to generate scrape configs")
+		return
+	}
+
+	targets := []APITargetGroup{}
+	found := job == ""
+	for _, config := range configs {
+		if job != "" && config.JobName != job {
+			continue
+		}
+		found = true
+		for _, group := range m.buildTargetGroups(config, snapshot, m.bucketFilter()) {
+			targets = append(targets, APITargetGroup{Job: config.JobName, Targets: group.Targets, Labels: group.Labels})
+		}
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "job %q not found", job)
+		return
+	}
+
+	page, ok := paginate(w, r, targets)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleAPIClusters handles GET /api/v1/clusters
+func (m *MinIOClient) handleAPIClusters(w http.ResponseWriter, r *http.Request) {
+	snapshot := m.Discover(r.Context())
+	writeJSON(w, http.StatusOK, []ClusterSummary{m.clusterSummary(snapshot, time.Now())})
+}
+
+// handleAPICluster handles GET /api/v1/clusters/{cluster}
+func (m *MinIOClient) handleAPICluster(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["cluster"]
+	snapshot := m.Discover(r.Context())
+	if name != snapshot.Cluster {
+		writeAPIError(w, http.StatusNotFound, "cluster %q not found", name)
+		return
+	}
+	writeJSON(w, http.StatusOK, m.clusterSummary(snapshot, time.Now()))
+}
+
+// handleAPIChanges handles GET /api/v1/changes
+func (m *MinIOClient) handleAPIChanges(w http.ResponseWriter, r *http.Request) {
+	page, ok := paginate(w, r, m.changes.Recent())
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleAPIConfig handles GET /api/v1/config
+func (m *MinIOClient) handleAPIConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, effectiveConfig(*m.cfg()))
+}
+
+// handleAPIReload handles GET /api/v1/reload
+func (m *MinIOClient) handleAPIReload(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, m.LastReload())
+}
+
+// handleOpenAPI handles GET /api/v1/openapi.json
+func (m *MinIOClient) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, GenerateOpenAPI(m.apiRoutes()))
+}
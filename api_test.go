@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+)
+
+// newTestAPI returns a client with a loaded snapshot and a router serving its API
+func newTestAPI(t *testing.T) (*MinIOClient, *mux.Router) {
+	t.Helper()
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.BucketExcludePattern = "*tmp*"
+	})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		UpdatedAt:   time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "online", Pools: []int{1}}, {Endpoint: "node2:9000", State: "offline"}},
+		Buckets: []minio.BucketInfo{
+			{Name: "alpha", CreationDate: created},
+			{Name: "beta", CreationDate: created},
+			{Name: "gamma", CreationDate: created},
+			{Name: "scratch-tmp", CreationDate: created},
+		},
+		Metadata: map[string]BucketMetadata{"alpha": {Versioning: "Enabled", Tags: map[string]string{"team": "a"}}},
+	}
+	client.changes.Record(ChangeEvent{Time: created, Cluster: "test", Kind: ChangeBucketAdded, Subject: "gamma"})
+
+	router := mux.NewRouter()
+	client.RegisterAPIRoutes(router)
+	return client, router
+}
+
+func serveAPI(router *mux.Router, method, target, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return recorder
+}
+
+// validate checks a decoded JSON value against a schema of the generated OpenAPI document
+func validate(components map[string]any, schema map[string]any, value any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validate(components, components[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]any), value, path)
+	}
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("%s: unexpected null", path)
+	}
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := validate(components, sub.(map[string]any), value, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: missing required property %s", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, property := range object {
+			propertySchema, ok := properties[name].(map[string]any)
+			if !ok {
+				propertySchema, ok = schema["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				return fmt.Errorf("%s: undocumented property %s", path, name)
+			}
+			if err := validate(components, propertySchema, property, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		for i, item := range array {
+			if err := validate(components, schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			return fmt.Errorf("%s: expected integer, got %v", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", path, value)
+		}
+	}
+	return nil
+}
+
+func TestAPIResponsesMatchOpenAPI(t *testing.T) {
+	client, router := newTestAPI(t)
+	doc := GenerateOpenAPI(client.apiRoutes())
+	components := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	// Every route must be registered on the router under the documented method
+	for path, item := range doc["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			match := &mux.RouteMatch{}
+			request := httptest.NewRequest(strings.ToUpper(method), strings.NewReplacer("{job}", "minio-buckets", "{cluster}", "test").Replace(path), nil)
+			if !router.Match(request, match) || match.MatchErr != nil {
+				t.Errorf("Documented route %s %s is not registered", method, path)
+			}
+		}
+	}
+
+	requests := map[string]struct{ method, target, body string }{
+		"/api/v1/jobs":               {http.MethodGet, "/api/v1/jobs", ""},
+		"/api/v1/jobs/{job}":         {http.MethodGet, "/api/v1/jobs/minio-buckets", ""},
+		"/api/v1/targets":            {http.MethodGet, "/api/v1/targets", ""},
+		"/api/v1/clusters":           {http.MethodGet, "/api/v1/clusters", ""},
+		"/api/v1/clusters/{cluster}": {http.MethodGet, "/api/v1/clusters/test", ""},
+		"/api/v1/nodes":              {http.MethodGet, "/api/v1/nodes", ""},
+		"/api/v1/buckets":            {http.MethodGet, "/api/v1/buckets", ""},
+		"/api/v1/changes":            {http.MethodGet, "/api/v1/changes", ""},
+		"/api/v1/config":             {http.MethodGet, "/api/v1/config", ""},
+		"/api/v1/reload":             {http.MethodGet, "/api/v1/reload", ""},
+		"/api/v1/shards":             {http.MethodGet, "/api/v1/shards?shards=2", ""},
+		"/api/v1/preview":            {http.MethodPost, "/api/v1/preview", "bucket_pattern: a*"},
+		"/api/v1/openapi.json":       {http.MethodGet, "/api/v1/openapi.json", ""},
+	}
+	for path, item := range doc["paths"].(map[string]any) {
+		req, ok := requests[path]
+		if !ok {
+			t.Errorf("No test request for documented path %s", path)
+			continue
+		}
+		operation := item.(map[string]any)[strings.ToLower(req.method)].(map[string]any)
+		responses := operation["responses"].(map[string]any)
+		schema := responses["200"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+
+		recorder := serveAPI(router, req.method, req.target, req.body)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status 200, got %d: %s", req.method, req.target, recorder.Code, recorder.Body.String())
+			continue
+		}
+		var body any
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s %s: invalid JSON: %v", req.method, req.target, err)
+			continue
+		}
+		if err := validate(components, schema, body, path); err != nil {
+			t.Errorf("%s %s: response does not match the OpenAPI schema: %v", req.method, req.target, err)
+		}
+	}
+}
+
+func TestAPIErrorEnvelope(t *testing.T) {
+	client, router := newTestAPI(t)
+	doc := GenerateOpenAPI(client.apiRoutes())
+	components := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	tests := []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodGet, "/api/v1/jobs/unknown", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/clusters/eu1", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/targets?job=unknown", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/unknown", http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/jobs", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/buckets?limit=0", http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/shards", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/preview", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		body := ""
+		if tt.method == http.MethodPost {
+			body = "unknown_key: x"
+		}
+		recorder := serveAPI(router, tt.method, tt.target, body)
+		if recorder.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.status, recorder.Code)
+			continue
+		}
+		var envelope any
+		if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+			t.Errorf("%s %s: invalid JSON: %v", tt.method, tt.target, err)
+			continue
+		}
+		if err := validate(components, map[string]any{"$ref": "#/components/schemas/APIErrorResponse"}, envelope, "error"); err != nil {
+			t.Errorf("%s %s: %v", tt.method, tt.target, err)
+		}
+		if code := envelope.(map[string]any)["error"].(map[string]any)["status"]; code != float64(tt.status) {
+			t.Errorf("%s %s: expected status %d in the envelope, got %v", tt.method, tt.target, tt.status, code)
+		}
+	}
+}
+
+func TestAPIPagination(t *testing.T) {
+	_, router := newTestAPI(t)
+
+	recorder := serveAPI(router, http.MethodGet, "/api/v1/buckets?limit=2&offset=1", "")
+	var entries []BucketInventoryEntry
+	if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "beta" || entries[1].Name != "gamma" {
+		t.Errorf("Expected beta and gamma, got %+v", entries)
+	}
+	if total := recorder.Header().Get("X-Total-Count"); total != "4" {
+		t.Errorf("Expected X-Total-Count 4, got %q", total)
+	}
+	if link := recorder.Header().Get("Link"); link != `</api/v1/buckets?limit=2&offset=3>; rel="next"` {
+		t.Errorf("Unexpected Link header: %q", link)
+	}
+
+	recorder = serveAPI(router, http.MethodGet, "/api/v1/buckets?limit=2&offset=3", "")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || recorder.Header().Get("Link") != "" {
+		t.Errorf("Expected the last bucket without a next link, got %d entries and %q", len(entries), recorder.Header().Get("Link"))
+	}
+}
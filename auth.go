@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authentication methods usable in route policies
+const (
+	AuthMethodNone       = "none"
+	AuthMethodBasic      = "basic"
+	AuthMethodBearer     = "bearer"
+	AuthMethodClientCert = "client_cert"
+)
+
+// AuthConfig holds the authentication settings for the HTTP endpoints.
+// Authentication is enabled as soon as any credential or a default policy is configured.
+type AuthConfig struct {
+	// BasicAuthUsers maps user names to bcrypt password hashes, as in Prometheus's web.config.file
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+	// BasicAuthUserFiles maps user names to files each containing a bcrypt password hash
+	BasicAuthUserFiles map[string]string `yaml:"basic_auth_user_files"`
+	// BearerTokenFiles lists files each containing one accepted bearer token
+	BearerTokenFiles []string `yaml:"bearer_token_files"`
+	// BearerTokenHashes lists hex-encoded SHA-256 hashes of accepted bearer tokens
+	BearerTokenHashes []string `yaml:"bearer_token_sha256"`
+	// ClientCertCNs restricts client certificates to these subject common names; empty accepts any verified certificate
+	ClientCertCNs []string `yaml:"client_cert_allowed_cns"`
+	// DefaultPolicy lists the methods accepted on routes without an explicit policy
+	DefaultPolicy []string `yaml:"default_policy"`
+	// RoutePolicies maps route paths (e.g. /sd) to the methods accepted on them; "none" leaves a route open
+	RoutePolicies map[string][]string `yaml:"route_policies"`
+}
+
+// Enabled reports whether any credential, default policy or route policy requiring
+// authentication is configured
+func (c AuthConfig) Enabled() bool {
+	if len(c.BasicAuthUsers) > 0 || len(c.BearerTokenFiles) > 0 || len(c.BearerTokenHashes) > 0 ||
+		len(c.ClientCertCNs) > 0 || len(c.DefaultPolicy) > 0 {
+		return true
+	}
+	for _, policy := range c.RoutePolicies {
+		if slices.ContainsFunc(policy, func(method string) bool { return method != AuthMethodNone }) {
+			return true
+		}
+	}
+	return false
+}
+
+// unbackedMethods reports every policy naming a method that no request can satisfy
+// because no credential of that kind is configured. Client certificates can only be
+// accepted when the listener verifies them against a client CA.
+func (c AuthConfig) unbackedMethods(verifiesClientCerts bool) []string {
+	policies := map[string][]string{"default_policy": c.DefaultPolicy}
+	for route, policy := range c.RoutePolicies {
+		policies["route_policies."+route] = policy
+	}
+	var problems []string
+	for _, key := range slices.Sorted(maps.Keys(policies)) {
+		for _, method := range policies[key] {
+			var missing string
+			switch {
+			case method == AuthMethodBasic && len(c.BasicAuthUsers) == 0:
+				missing = "basic_auth_users or basic_auth_user_files"
+			case method == AuthMethodBearer && len(c.BearerTokenFiles) == 0 && len(c.BearerTokenHashes) == 0:
+				missing = "bearer_token_files or bearer_token_sha256"
+			case method == AuthMethodClientCert && !verifiesClientCerts:
+				missing = "a client_ca_file in the web config file"
+			default:
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s: method %s requires %s", key, method, missing))
+		}
+	}
+	return problems
+}
+
+// openRoutes are left unauthenticated unless a route policy says otherwise
+var openRoutes = []string{"/health", "/-/healthy", "/-/ready"}
+
+// Authenticator enforces the configured authentication policies
+type Authenticator struct {
+	config      AuthConfig
+	tokenHashes [][]byte
+
+	// Successful basic auth checks are cached because bcrypt is deliberately slow
+	cacheMu sync.Mutex
+	cache   map[string]bool
+}
+
+// NewAuthenticator validates the auth configuration and loads the bearer token files
+func NewAuthenticator(config AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		config: config,
+		cache:  make(map[string]bool),
+	}
+
+	for user, hash := range config.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash for user %s: %w", user, err)
+		}
+	}
+
+	for _, file := range config.BearerTokenFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bearer token file %s: %w", file, err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return nil, fmt.Errorf("bearer token file %s is empty", file)
+		}
+		sum := sha256.Sum256([]byte(token))
+		a.tokenHashes = append(a.tokenHashes, sum[:])
+	}
+
+	for _, hash := range config.BearerTokenHashes {
+		sum, err := hex.DecodeString(hash)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid bearer token sha256 %q", hash)
+		}
+		a.tokenHashes = append(a.tokenHashes, sum)
+	}
+
+	policies := [][]string{config.DefaultPolicy}
+	for _, policy := range config.RoutePolicies {
+		policies = append(policies, policy)
+	}
+	for _, policy := range policies {
+		for _, method := range policy {
+			switch method {
+			case AuthMethodNone, AuthMethodBasic, AuthMethodBearer, AuthMethodClientCert:
+			default:
+				return nil, fmt.Errorf("unknown auth method %q (valid: none, basic, bearer, client_cert)", method)
+			}
+		}
+	}
+
+	return a, nil
+}
+
+// newAuthenticatorFor builds the authenticator of an auth configuration, reading the bearer
+// token files. It returns nil when no credential or policy is configured.
+func newAuthenticatorFor(config AuthConfig) (*Authenticator, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+	return NewAuthenticator(config)
+}
+
+// AuthMiddleware enforces the policies of the authenticator currently in effect, so that
+// credentials rotated or changed on reload apply to the next request
+func (m *MinIOClient) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authenticator := m.authenticator.Load(); authenticator != nil {
+			authenticator.Middleware(next).ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated reports whether a request satisfies the default policy, whatever the
+// policy of its route. Open routes use it to reveal details to authenticated callers only.
+// Every request is authenticated when authentication is disabled.
+func (m *MinIOClient) authenticated(r *http.Request) bool {
+	authenticator := m.authenticator.Load()
+	if authenticator == nil {
+		return true
+	}
+	methods := authenticator.config.defaultPolicy()
+	if slices.Contains(methods, AuthMethodNone) {
+		return true
+	}
+	for _, method := range methods {
+		if ok, _ := authenticator.authenticate(method, r); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// policy returns the methods accepted on a route
+func (c AuthConfig) policy(route string) []string {
+	if policy, ok := c.RoutePolicies[route]; ok {
+		return policy
+	}
+	if slices.Contains(openRoutes, route) {
+		return []string{AuthMethodNone}
+	}
+	return c.defaultPolicy()
+}
+
+// defaultPolicy returns the methods accepted on routes without an explicit policy
+func (c AuthConfig) defaultPolicy() []string {
+	if len(c.DefaultPolicy) > 0 {
+		return c.DefaultPolicy
+	}
+
+	// Default to every method that has credentials configured
+	var methods []string
+	if len(c.BasicAuthUsers) > 0 {
+		methods = append(methods, AuthMethodBasic)
+	}
+	if len(c.BearerTokenFiles) > 0 || len(c.BearerTokenHashes) > 0 {
+		methods = append(methods, AuthMethodBearer)
+	}
+	if len(c.ClientCertCNs) > 0 {
+		methods = append(methods, AuthMethodClientCert)
+	}
+	return methods
+}
+
+// Middleware rejects requests that do not satisfy the policy of the matched route
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		methods := a.config.policy(route)
+		if slices.Contains(methods, AuthMethodNone) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var reasons []string
+		for _, method := range methods {
+			ok, reason := a.authenticate(method, r)
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+
+		user, _, _ := r.BasicAuth()
+		logrus.WithFields(logrus.Fields{
+			"remote_addr": r.RemoteAddr,
+			"method":      r.Method,
+			"route":       route,
+			"user":        user,
+			"reason":      strings.Join(reasons, "; "),
+		}).Warn("Rejected unauthenticated request")
+
+		if slices.Contains(methods, AuthMethodBasic) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="eos-mb-http-sd"`)
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeAPIError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authenticate checks a request against a single method, returning why it failed if it did
+func (a *Authenticator) authenticate(method string, r *http.Request) (bool, string) {
+	switch method {
+	case AuthMethodBasic:
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return false, "no basic auth credentials"
+		}
+		if !a.checkPassword(user, password) {
+			return false, "invalid basic auth credentials"
+		}
+		return true, ""
+	case AuthMethodBearer:
+		header := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return false, "no bearer token"
+		}
+		sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+		for _, hash := range a.tokenHashes {
+			if subtle.ConstantTimeCompare(sum[:], hash) == 1 {
+				return true, ""
+			}
+		}
+		return false, "invalid bearer token"
+	case AuthMethodClientCert:
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return false, "no verified client certificate"
+		}
+		if len(a.config.ClientCertCNs) == 0 {
+			return true, ""
+		}
+		commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if slices.Contains(a.config.ClientCertCNs, commonName) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("client certificate CN %q not allowed", commonName)
+	default:
+		return false, ""
+	}
+}
+
+// checkPassword verifies a basic auth password against its bcrypt hash
+func (a *Authenticator) checkPassword(user, password string) bool {
+	hash, ok := a.config.BasicAuthUsers[user]
+	if !ok {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(user + ":" + password + ":" + hash))
+	key := string(sum[:])
+	a.cacheMu.Lock()
+	cached := a.cache[key]
+	a.cacheMu.Unlock()
+	if cached {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	a.cacheMu.Lock()
+	a.cache[key] = true
+	a.cacheMu.Unlock()
+	return true
+}
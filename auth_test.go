@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuthTestServer(t *testing.T, config AuthConfig) *httptest.Server {
+	t.Helper()
+	authenticator, err := NewAuthenticator(config)
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(authenticator.Middleware)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/sd", ok)
+	router.HandleFunc("/scrape_configs", ok)
+	router.HandleFunc("/health", ok)
+	router.HandleFunc("/api/v1/jobs", ok)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthenticatorPolicies(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	tokenHash := sha256.Sum256([]byte("hashed-token"))
+
+	server := newAuthTestServer(t, AuthConfig{
+		BasicAuthUsers:    map[string]string{"prometheus": string(hash)},
+		BearerTokenFiles:  []string{tokenFile},
+		BearerTokenHashes: []string{hex.EncodeToString(tokenHash[:])},
+		RoutePolicies:     map[string][]string{"/scrape_configs": {AuthMethodBearer}},
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		user     string
+		password string
+		token    string
+		expected int
+	}{
+		{"health is open", "/health", "", "", "", http.StatusOK},
+		{"sd without credentials", "/sd", "", "", "", http.StatusUnauthorized},
+		{"sd with basic auth", "/sd", "prometheus", "secret", "", http.StatusOK},
+		{"sd with wrong password", "/sd", "prometheus", "wrong", "", http.StatusUnauthorized},
+		{"sd with token from file", "/sd", "", "", "file-token", http.StatusOK},
+		{"sd with hashed token", "/sd", "", "", "hashed-token", http.StatusOK},
+		{"sd with wrong token", "/sd", "", "", "other", http.StatusUnauthorized},
+		{"bearer-only route rejects basic auth", "/scrape_configs", "prometheus", "secret", "", http.StatusUnauthorized},
+		{"bearer-only route accepts token", "/scrape_configs", "", "", "file-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorAPIErrorEnvelope(t *testing.T) {
+	tokenHash := sha256.Sum256([]byte("token"))
+	server := newAuthTestServer(t, AuthConfig{BearerTokenHashes: []string{hex.EncodeToString(tokenHash[:])}})
+
+	resp, err := http.Get(server.URL + "/api/v1/jobs")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var body APIErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected a JSON error envelope: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized || body.Error.Status != http.StatusUnauthorized || body.Error.Code != "unauthorized" {
+		t.Errorf("Expected a 401 unauthorized envelope, got %d %+v", resp.StatusCode, body)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", contentType)
+	}
+}
+
+func TestNewAuthenticatorRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AuthConfig
+	}{
+		{"plain text password", AuthConfig{BasicAuthUsers: map[string]string{"user": "secret"}}},
+		{"missing token file", AuthConfig{BearerTokenFiles: []string{"/nonexistent/token"}}},
+		{"invalid token hash", AuthConfig{BearerTokenHashes: []string{"abc"}}},
+		{"unknown method", AuthConfig{DefaultPolicy: []string{"digest"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAuthenticator(tt.config); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRoutePolicyOnlyAuth(t *testing.T) {
+	for _, method := range []string{AuthMethodClientCert, AuthMethodBearer} {
+		t.Run(method, func(t *testing.T) {
+			config := AuthConfig{RoutePolicies: map[string][]string{"/sd": {method}}}
+			if !config.Enabled() {
+				t.Fatalf("Expected a route policy to enable authentication")
+			}
+			authenticator, err := newAuthenticatorFor(config)
+			if err != nil || authenticator == nil {
+				t.Fatalf("Expected an authenticator, got %v, %v", authenticator, err)
+			}
+
+			router := mux.NewRouter()
+			router.Use(authenticator.Middleware)
+			router.HandleFunc("/sd", func(w http.ResponseWriter, r *http.Request) {})
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/sd", nil))
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401 on /sd, got %d", recorder.Code)
+			}
+		})
+	}
+
+	open := AuthConfig{RoutePolicies: map[string][]string{"/sd": {AuthMethodNone}}}
+	if open.Enabled() {
+		t.Errorf("Expected a route policy of none to leave authentication off")
+	}
+}
+
+func TestUnbackedAuthMethods(t *testing.T) {
+	tokenHash := sha256.Sum256([]byte("token"))
+	tests := []struct {
+		name                string
+		config              AuthConfig
+		verifiesClientCerts bool
+		expected            []string
+	}{
+		{"bearer without tokens", AuthConfig{RoutePolicies: map[string][]string{"/sd": {AuthMethodBearer}}}, false,
+			[]string{"route_policies./sd: method bearer requires bearer_token_files or bearer_token_sha256"}},
+		{"client cert without CA", AuthConfig{DefaultPolicy: []string{AuthMethodClientCert}}, false,
+			[]string{"default_policy: method client_cert requires a client_ca_file in the web config file"}},
+		{"basic without users", AuthConfig{DefaultPolicy: []string{AuthMethodBasic, AuthMethodNone}}, false,
+			[]string{"default_policy: method basic requires basic_auth_users or basic_auth_user_files"}},
+		{"client cert with CA", AuthConfig{DefaultPolicy: []string{AuthMethodClientCert}}, true, nil},
+		{"bearer with token", AuthConfig{BearerTokenHashes: []string{hex.EncodeToString(tokenHash[:])}, RoutePolicies: map[string][]string{"/sd": {AuthMethodBearer}}}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.unbackedMethods(tt.verifiesClientCerts); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a call is rejected because the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState represents the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects calls until the backoff period has elapsed
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to test recovery
+	BreakerHalfOpen
+)
+
+// String returns the human readable name of the breaker state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerStatus is a point-in-time view of a circuit breaker, used for health and metrics reporting
+type BreakerStatus struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Trips               int       `json:"trips"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitzero"`
+	RetryAt             time.Time `json:"retry_at,omitzero"`
+}
+
+// CircuitBreaker guards calls to a single MinIO cluster.
+// After a threshold of consecutive failures it opens and rejects calls for an
+// exponentially growing backoff period (doubling on every failed probe, capped at the maximum backoff).
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	failureThreshold int
+	initialBackoff   time.Duration
+	maxBackoff       time.Duration
+
+	state       BreakerState
+	failures    int
+	trips       int
+	probing     bool
+	lastError   string
+	lastFailure time.Time
+	retryAt     time.Time
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a new circuit breaker
+func NewCircuitBreaker(failureThreshold int, initialBackoff, maxBackoff time.Duration) *CircuitBreaker {
+	b := &CircuitBreaker{now: time.Now}
+	b.Configure(failureThreshold, initialBackoff, maxBackoff)
+	return b
+}
+
+// Configure changes the failure threshold and backoff bounds. The current state is kept;
+// the new settings apply from the next failure on.
+func (b *CircuitBreaker) Configure(failureThreshold int, initialBackoff, maxBackoff time.Duration) {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if maxBackoff < initialBackoff {
+		maxBackoff = initialBackoff
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failureThreshold = failureThreshold
+	b.initialBackoff = initialBackoff
+	b.maxBackoff = maxBackoff
+}
+
+// Reset closes the breaker and forgets past failures, e.g. after switching to another endpoint
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trips = 0
+	b.probing = false
+	b.lastError = ""
+	b.lastFailure = time.Time{}
+	b.retryAt = time.Time{}
+}
+
+// Allow reports whether a call may proceed. It returns ErrCircuitOpen while the breaker
+// is open, and lets exactly one probe through once the backoff period has elapsed.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.retryAt) {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success records a successful call and closes the breaker
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trips = 0
+	b.probing = false
+	b.retryAt = time.Time{}
+}
+
+// Abandon releases a probe slot without recording an outcome, used when the caller gave up
+// on the call (e.g. the inbound request was cancelled) before MinIO answered
+func (b *CircuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Failure records a failed call, opening the breaker once the threshold is reached
+// or immediately if the failed call was a half-open probe
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastFailure = b.now()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+
+	if b.state == BreakerHalfOpen || b.failures >= b.failureThreshold {
+		b.trips++
+		b.state = BreakerOpen
+		b.probing = false
+		b.retryAt = b.lastFailure.Add(b.backoff())
+	}
+}
+
+// backoff returns the open period for the current number of consecutive trips
+func (b *CircuitBreaker) backoff() time.Duration {
+	backoff := b.initialBackoff
+	for i := 1; i < b.trips; i++ {
+		backoff *= 2
+		if backoff >= b.maxBackoff {
+			return b.maxBackoff
+		}
+	}
+	return backoff
+}
+
+// State returns the current breaker state
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Status returns a snapshot of the breaker for reporting
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BreakerStatus{
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips,
+		LastError:           b.lastError,
+		LastFailure:         b.lastFailure,
+		RetryAt:             b.retryAt,
+	}
+}
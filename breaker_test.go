@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(2, time.Second, 4*time.Second)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Failure(errors.New("boom"))
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Expected call to be allowed below threshold, got %v", err)
+	}
+
+	breaker.Failure(errors.New("boom"))
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("Expected breaker to be open, got %s", breaker.State())
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	// After the backoff a single probe is allowed
+	now = now.Add(time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected concurrent probe to be rejected, got %v", err)
+	}
+
+	breaker.Success()
+	if breaker.State() != BreakerClosed {
+		t.Errorf("Expected breaker to be closed after success, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerExponentialBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(1, time.Second, 4*time.Second)
+	breaker.now = func() time.Time { return now }
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, backoff := range expected {
+		breaker.Failure(errors.New("boom"))
+		status := breaker.Status()
+		if got := status.RetryAt.Sub(now); got != backoff {
+			t.Errorf("Trip %d: expected backoff %v, got %v", i+1, backoff, got)
+		}
+		now = status.RetryAt
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("Trip %d: expected probe to be allowed, got %v", i+1, err)
+		}
+	}
+}
+
+func TestEnrichmentBypassesCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("location") {
+			fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+			return
+		}
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied.</Message></Error>`)
+	}))
+	defer server.Close()
+
+	config := testConfig(func(c *Config) {
+		c.MinIOEndpoint = strings.TrimPrefix(server.URL, "http://")
+		c.BucketEnrichment = true
+		c.EnrichmentWorkers = 2
+		c.EnrichmentDeadline = 10 * time.Second
+		c.BreakerFailureThreshold = 1
+	})
+	client, err := NewMinIOClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create MinIO client: %v", err)
+	}
+
+	buckets := []minio.BucketInfo{{Name: "alpha"}, {Name: "beta"}, {Name: "gamma"}}
+	metadata := client.enrichBuckets(context.Background(), buckets, nil)
+	if len(metadata) != 3 {
+		t.Fatalf("Expected metadata for 3 buckets, got %v", metadata)
+	}
+	for name, details := range metadata {
+		if details.Versioning != "" || details.Tags != nil {
+			t.Errorf("Expected no metadata for %s without permissions, got %+v", name, details)
+		}
+	}
+	if state := client.breaker.State(); state != BreakerClosed {
+		t.Errorf("Expected the breaker to stay closed, got %v", state)
+	}
+
+	config.BucketEnrichment = false
+	if _, err := client.ApplyConfig(config); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	calls.Store(0)
+	if metadata := client.enrichBuckets(context.Background(), buckets, nil); metadata != nil || calls.Load() != 0 {
+		t.Errorf("Expected no calls with enrichment off, got %d calls and %v", calls.Load(), metadata)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Kinds of discovery changes recorded between snapshots
+const (
+	ChangeNodeAdded     = "node_added"
+	ChangeNodeRemoved   = "node_removed"
+	ChangeNodeState     = "node_state"
+	ChangeBucketAdded   = "bucket_added"
+	ChangeBucketRemoved = "bucket_removed"
+)
+
+// maxChanges is the number of recent changes kept in memory
+const maxChanges = 100
+
+// ChangeEvent describes a node or bucket that appeared, disappeared or changed state between two snapshots
+type ChangeEvent struct {
+	Time    time.Time `json:"time"`
+	Cluster string    `json:"cluster"`
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"` // Node endpoint or bucket name
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// ChangeLog keeps the most recent discovery changes
+type ChangeLog struct {
+	mu     sync.Mutex
+	events []ChangeEvent
+	limit  int
+}
+
+// NewChangeLog creates a change log keeping at most limit events
+func NewChangeLog(limit int) *ChangeLog {
+	return &ChangeLog{limit: limit}
+}
+
+// Record appends events, dropping the oldest ones beyond the limit
+func (l *ChangeLog) Record(events ...ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, events...)
+	if excess := len(l.events) - l.limit; excess > 0 {
+		l.events = slices.Delete(l.events, 0, excess)
+	}
+}
+
+// Recent returns the recorded events, newest first
+func (l *ChangeLog) Recent() []ChangeEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := make([]ChangeEvent, 0, len(l.events))
+	recent = append(recent, l.events...)
+	slices.Reverse(recent)
+	return recent
+}
+
+// diffSnapshots lists the nodes and buckets that changed between two snapshots. Nothing is
+// reported for the first snapshot, since everything would show up as added.
+func diffSnapshots(previous, current *Snapshot) []ChangeEvent {
+	if previous == nil || current == nil {
+		return nil
+	}
+	var events []ChangeEvent
+	event := func(kind, subject, detail string) {
+		events = append(events, ChangeEvent{
+			Time:    current.RefreshedAt,
+			Cluster: current.Cluster,
+			Kind:    kind,
+			Subject: subject,
+			Detail:  detail,
+		})
+	}
+
+	previousNodes := make(map[string]NodeInfo, len(previous.Nodes))
+	for _, node := range previous.Nodes {
+		previousNodes[node.Endpoint] = node
+	}
+	currentNodes := make(map[string]bool, len(current.Nodes))
+	for _, node := range current.Nodes {
+		currentNodes[node.Endpoint] = true
+		old, ok := previousNodes[node.Endpoint]
+		switch {
+		case !ok:
+			event(ChangeNodeAdded, node.Endpoint, node.State)
+		case old.State != node.State:
+			event(ChangeNodeState, node.Endpoint, old.State+" -> "+node.State)
+		}
+	}
+	for _, node := range previous.Nodes {
+		if !currentNodes[node.Endpoint] {
+			event(ChangeNodeRemoved, node.Endpoint, "")
+		}
+	}
+
+	previousBuckets := make(map[string]bool, len(previous.Buckets))
+	for _, bucket := range previous.Buckets {
+		previousBuckets[bucket.Name] = true
+	}
+	currentBuckets := make(map[string]bool, len(current.Buckets))
+	for _, bucket := range current.Buckets {
+		currentBuckets[bucket.Name] = true
+		if !previousBuckets[bucket.Name] {
+			event(ChangeBucketAdded, bucket.Name, "")
+		}
+	}
+	for _, bucket := range previous.Buckets {
+		if !currentBuckets[bucket.Name] {
+			event(ChangeBucketRemoved, bucket.Name, "")
+		}
+	}
+	return events
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	previous := &Snapshot{
+		Cluster: "test",
+		Nodes:   []NodeInfo{{Endpoint: "node1:9000", State: "online"}, {Endpoint: "node2:9000", State: "online"}},
+		Buckets: []minio.BucketInfo{{Name: "alpha"}, {Name: "beta"}},
+	}
+	current := &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "offline"}, {Endpoint: "node3:9000", State: "online"}},
+		Buckets:     []minio.BucketInfo{{Name: "beta"}, {Name: "gamma"}},
+	}
+
+	if events := diffSnapshots(nil, current); len(events) != 0 {
+		t.Errorf("Expected no changes for the first snapshot, got %v", events)
+	}
+
+	expected := []ChangeEvent{
+		{Kind: ChangeNodeState, Subject: "node1:9000", Detail: "online -> offline"},
+		{Kind: ChangeNodeAdded, Subject: "node3:9000", Detail: "online"},
+		{Kind: ChangeNodeRemoved, Subject: "node2:9000"},
+		{Kind: ChangeBucketAdded, Subject: "gamma"},
+		{Kind: ChangeBucketRemoved, Subject: "alpha"},
+	}
+	events := diffSnapshots(previous, current)
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d changes, got %v", len(expected), events)
+	}
+	for i, event := range events {
+		want := expected[i]
+		if event.Kind != want.Kind || event.Subject != want.Subject || event.Detail != want.Detail {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want, event)
+		}
+		if event.Cluster != "test" || !event.Time.Equal(current.RefreshedAt) {
+			t.Errorf("Change %d: unexpected cluster or time: %+v", i, event)
+		}
+	}
+}
+
+func TestChangeLog(t *testing.T) {
+	log := NewChangeLog(2)
+	log.Record(ChangeEvent{Subject: "a"}, ChangeEvent{Subject: "b"})
+	log.Record(ChangeEvent{Subject: "c"})
+
+	recent := log.Recent()
+	if len(recent) != 2 || recent[0].Subject != "c" || recent[1].Subject != "b" {
+		t.Errorf("Expected the 2 newest changes, newest first, got %v", recent)
+	}
+}
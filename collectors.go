@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// otelReceiverName is the name of the generated OpenTelemetry Collector receiver
+const otelReceiverName = "prometheus/minio"
+
+// OTelCollectorConfig is the receivers section of an OpenTelemetry Collector configuration
+type OTelCollectorConfig struct {
+	Receivers map[string]OTelPrometheusReceiver `yaml:"receivers"`
+}
+
+// OTelPrometheusReceiver is the configuration of the Collector prometheus receiver,
+// which embeds a Prometheus configuration
+type OTelPrometheusReceiver struct {
+	Config PrometheusConfig `yaml:"config"`
+}
+
+// RenderOTelCollectorConfig renders the discovered jobs as a Collector prometheus receiver
+// discovering its targets through http_sd_configs against this service
+func (m *MinIOClient) RenderOTelCollectorConfig(ctx context.Context) ([]byte, error) {
+	prometheusConfig, err := m.GeneratePrometheusConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Prometheus config: %w", err)
+	}
+
+	data, err := yaml.Marshal(OTelCollectorConfig{
+		Receivers: map[string]OTelPrometheusReceiver{otelReceiverName: {Config: prometheusConfig}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenTelemetry Collector config: %w", err)
+	}
+	// The Collector expands ${...} and $VAR in its config, so literal dollars must be doubled
+	return []byte(strings.ReplaceAll(string(data), "$", "$$")), nil
+}
+
+// alloyLabelInvalid matches characters not allowed in Alloy component labels
+var alloyLabelInvalid = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// alloyComponentLabel turns a job name into a valid Alloy component label
+func alloyComponentLabel(job string) string {
+	return alloyLabelInvalid.ReplaceAllString(job, "_")
+}
+
+// alloyAuthorization renders an Alloy authorization block
+func alloyAuthorization(b *strings.Builder, indent string, authorization *PrometheusAuthorization) {
+	if authorization == nil {
+		return
+	}
+	fmt.Fprintf(b, "%sauthorization {\n", indent)
+	fmt.Fprintf(b, "%s  type = %s\n", indent, strconv.Quote(authorization.Type))
+	fmt.Fprintf(b, "%s  credentials_file = %s\n", indent, strconv.Quote(authorization.CredentialsFile))
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// alloyBasicAuth renders an Alloy basic_auth block
+func alloyBasicAuth(b *strings.Builder, indent string, basicAuth *PrometheusBasicAuth) {
+	if basicAuth == nil {
+		return
+	}
+	fmt.Fprintf(b, "%sbasic_auth {\n", indent)
+	fmt.Fprintf(b, "%s  username = %s\n", indent, strconv.Quote(basicAuth.Username))
+	fmt.Fprintf(b, "%s  password_file = %s\n", indent, strconv.Quote(basicAuth.PasswordFile))
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// alloyTLSConfig renders an Alloy tls_config block
+func alloyTLSConfig(b *strings.Builder, indent string, tlsConfig *PrometheusTLSConfig) {
+	if tlsConfig == nil {
+		return
+	}
+	fmt.Fprintf(b, "%stls_config {\n", indent)
+	for _, attribute := range []struct{ name, value string }{
+		{"ca_file", tlsConfig.CAFile},
+		{"cert_file", tlsConfig.CertFile},
+		{"key_file", tlsConfig.KeyFile},
+	} {
+		if attribute.value != "" {
+			fmt.Fprintf(b, "%s  %s = %s\n", indent, attribute.name, strconv.Quote(attribute.value))
+		}
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// RenderAlloyConfig renders every job as Grafana Alloy discovery.http and prometheus.scrape
+// components, with a discovery.relabel component in between for jobs needing relabel rules.
+// Scraped samples are forwarded to the configured receiver.
+func (m *MinIOClient) RenderAlloyConfig(ctx context.Context) ([]byte, error) {
+	prometheusConfig, err := m.GeneratePrometheusConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Prometheus config: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by eos_mb_http_sd for cluster %s\n", m.cfg().ClusterName)
+	for _, job := range prometheusConfig.ScrapeConfigs {
+		label := alloyComponentLabel(job.JobName)
+		targets := "discovery.http." + label + ".targets"
+
+		for _, sd := range job.HTTPSDConfigs {
+			fmt.Fprintf(&b, "\ndiscovery.http %s {\n", strconv.Quote(label))
+			fmt.Fprintf(&b, "  url = %s\n", strconv.Quote(sd.URL))
+			fmt.Fprintf(&b, "  refresh_interval = %s\n", strconv.Quote(sd.RefreshInterval))
+			alloyBasicAuth(&b, "  ", sd.BasicAuth)
+			alloyAuthorization(&b, "  ", sd.Authorization)
+			alloyTLSConfig(&b, "  ", sd.TLSConfig)
+			b.WriteString("}\n")
+		}
+
+		if len(job.RelabelConfigs) > 0 {
+			fmt.Fprintf(&b, "\ndiscovery.relabel %s {\n", strconv.Quote(label))
+			fmt.Fprintf(&b, "  targets = %s\n", targets)
+			for _, rule := range job.RelabelConfigs {
+				b.WriteString("\n  rule {\n")
+				if len(rule.SourceLabels) > 0 {
+					quoted := make([]string, 0, len(rule.SourceLabels))
+					for _, source := range rule.SourceLabels {
+						quoted = append(quoted, strconv.Quote(source))
+					}
+					fmt.Fprintf(&b, "    source_labels = [%s]\n", strings.Join(quoted, ", "))
+				}
+				for _, attribute := range []struct{ name, value string }{
+					{"separator", rule.Separator},
+					{"regex", rule.Regex},
+					{"target_label", rule.TargetLabel},
+					{"replacement", rule.Replacement},
+					{"action", rule.Action},
+				} {
+					if attribute.value != "" {
+						fmt.Fprintf(&b, "    %s = %s\n", attribute.name, strconv.Quote(attribute.value))
+					}
+				}
+				b.WriteString("  }\n")
+			}
+			b.WriteString("}\n")
+			targets = "discovery.relabel." + label + ".output"
+		}
+
+		fmt.Fprintf(&b, "\nprometheus.scrape %s {\n", strconv.Quote(label))
+		fmt.Fprintf(&b, "  targets = %s\n", targets)
+		fmt.Fprintf(&b, "  forward_to = [%s]\n", m.cfg().AlloyForwardTo)
+		fmt.Fprintf(&b, "  job_name = %s\n", strconv.Quote(job.JobName))
+		for _, attribute := range []struct{ name, value string }{
+			{"scrape_interval", job.ScrapeInterval},
+			{"scrape_timeout", job.ScrapeTimeout},
+			{"metrics_path", job.MetricsPath},
+			{"scheme", job.Scheme},
+		} {
+			if attribute.value != "" {
+				fmt.Fprintf(&b, "  %s = %s\n", attribute.name, strconv.Quote(attribute.value))
+			}
+		}
+		alloyAuthorization(&b, "  ", job.Authorization)
+		b.WriteString("}\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// handleOTelCollectorConfig handles the /otelcol/receivers.yaml endpoint
+func (m *MinIOClient) handleOTelCollectorConfig(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("OpenTelemetry Collector config request from %s", r.RemoteAddr)
+
+	data, err := m.RenderOTelCollectorConfig(r.Context())
+	if err != nil {
+		logrus.Errorf("Failed to render OpenTelemetry Collector config: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
+// handleAlloyConfig handles the /alloy/config.alloy endpoint
+func (m *MinIOClient) handleAlloyConfig(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("Alloy config request from %s", r.RemoteAddr)
+
+	data, err := m.RenderAlloyConfig(r.Context())
+	if err != nil {
+		logrus.Errorf("Failed to render Alloy config: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
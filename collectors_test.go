@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderOTelCollectorConfig(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ExternalURL = "http://sd.example.com:8080"
+		c.PrometheusMinIOTokenFile = "/etc/otelcol/minio.token"
+	})
+
+	data, err := client.RenderOTelCollectorConfig(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to render OpenTelemetry Collector config: %v", err)
+	}
+
+	var config OTelCollectorConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	receiver, ok := config.Receivers[otelReceiverName]
+	if !ok || len(receiver.Config.ScrapeConfigs) != 2 {
+		t.Fatalf("Expected receiver %s with 2 jobs, got %+v", otelReceiverName, config.Receivers)
+	}
+	job := receiver.Config.ScrapeConfigs[0]
+	if job.HTTPSDConfigs[0].URL != "http://sd.example.com:8080/sd?job=minio-server" {
+		t.Errorf("Unexpected http_sd_configs URL: %s", job.HTTPSDConfigs[0].URL)
+	}
+	if job.Authorization == nil || job.Authorization.CredentialsFile != "/etc/otelcol/minio.token" {
+		t.Errorf("Expected MinIO bearer token file, got %+v", job.Authorization)
+	}
+}
+
+func TestRenderAlloyConfig(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ExternalURL = "http://sd.example.com:8080"
+		c.PrometheusMinIOTokenFile = "/etc/alloy/minio.token"
+		c.AlloyForwardTo = "prometheus.remote_write.mimir.receiver"
+	})
+
+	data, err := client.RenderAlloyConfig(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to render Alloy config: %v", err)
+	}
+	output := string(data)
+
+	for _, expected := range []string{
+		`discovery.http "minio_server" {`,
+		`url = "http://sd.example.com:8080/sd?job=minio-buckets"`,
+		`discovery.relabel "minio_buckets" {`,
+		`targets = discovery.relabel.minio_buckets.output`,
+		`targets = discovery.http.minio_server.targets`,
+		`forward_to = [prometheus.remote_write.mimir.receiver]`,
+		`credentials_file = "/etc/alloy/minio.token"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %s, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestRenderAlloyConfigSDCredentials(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.Auth.BasicAuthUsers = map[string]string{"prometheus": "$2y$10$mDwo.lAisC94iLAyP81MCesa29IzH37oigHC/42V2pdJlUprsJPze"}
+		c.TLSEnabled = true
+		c.TLSServer = &TLSServerConfig{CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt"}
+	})
+
+	data, err := client.RenderAlloyConfig(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to render Alloy config: %v", err)
+	}
+	output := string(data)
+
+	for _, expected := range []string{
+		`url = "https://localhost:8080/sd?job=minio-server"`,
+		"  basic_auth {\n    username = \"prometheus\"\n    password_file = \"" + prometheusSDPasswordFile + "\"\n  }",
+		"  tls_config {\n    ca_file = \"" + prometheusSDCAFile + "\"\n    cert_file = \"" + prometheusSDCertFile + "\"\n    key_file = \"" + prometheusSDKeyFile + "\"\n  }",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %s, got:\n%s", expected, output)
+		}
+	}
+}
@@ -0,0 +1,738 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources a setting can come from, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceProfile = "profile" // The selected profile of the config file
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// configField describes a setting that can be given in the config file, as an environment
+// variable and as a command line flag. The environment variable and flag names are derived
+// from the config file key, e.g. scrape_interval, SCRAPE_INTERVAL and -scrape-interval.
+type configField struct {
+	key    string
+	def    string // Default value, in flag syntax
+	usage  string
+	isBool bool
+	enum   []string       // Accepted values, if restricted
+	env    string         // Environment variable, if not the upper-case key
+	schema map[string]any // JSON Schema of the value in the config file
+	parse  func(config *Config, value string) error
+}
+
+// oneOf restricts a setting to the given values
+func (f configField) oneOf(values ...string) configField {
+	parse := f.parse
+	f.enum = values
+	f.usage = fmt.Sprintf("%s (%s)", f.usage, strings.Join(values, ", "))
+	f.parse = func(config *Config, value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("invalid value %q, expected one of %s", value, strings.Join(values, ", "))
+		}
+		return parse(config, value)
+	}
+	return f
+}
+
+// withEnv reads a setting from the given environment variable instead of the upper-case
+// key, for keys whose plain name is commonly set for other purposes
+func (f configField) withEnv(name string) configField {
+	f.env = name
+	return f
+}
+
+// flagName returns the command line flag of the setting
+func (f configField) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+// envName returns the environment variable of the setting
+func (f configField) envName() string {
+	if f.env != "" {
+		return f.env
+	}
+	return strings.ToUpper(f.key)
+}
+
+func stringField(key, def, usage string, target func(config *Config) *string) configField {
+	return configField{key: key, def: def, usage: usage, schema: map[string]any{"type": "string"}, parse: func(config *Config, value string) error {
+		*target(config) = value
+		return nil
+	}}
+}
+
+func boolField(key string, def bool, usage string, target func(config *Config) *bool) configField {
+	return configField{key: key, def: strconv.FormatBool(def), usage: usage, isBool: true, schema: map[string]any{"type": "boolean"}, parse: func(config *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*target(config) = parsed
+		return nil
+	}}
+}
+
+func intField(key string, def int, usage string, target func(config *Config) *int) configField {
+	return configField{key: key, def: strconv.Itoa(def), usage: usage, schema: map[string]any{"type": "integer"}, parse: func(config *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*target(config) = parsed
+		return nil
+	}}
+}
+
+// durationPattern matches the durations accepted by time.ParseDuration
+const durationPattern = `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|μs|ms|s|m|h))+)$`
+
+func durationField(key string, def time.Duration, usage string, target func(config *Config) *time.Duration) configField {
+	return configField{key: key, def: def.String(), usage: usage, schema: map[string]any{"type": "string", "pattern": durationPattern}, parse: func(config *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*target(config) = parsed
+		return nil
+	}}
+}
+
+// listField is a list setting, comma-separated in environment variables and flags
+func listField(key, def, usage string, target func(config *Config) *[]string) configField {
+	return configField{key: key, def: def, usage: usage, schema: map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, parse: func(config *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target(config) = list
+		return nil
+	}}
+}
+
+// configFields lists the layered settings. Authentication and per-job overrides are only
+// read from the config file.
+var configFields = []configField{
+	stringField("profile", "", "Profile of the config file to merge into its base settings", func(c *Config) *string { return &c.Profile }).withEnv(ProfileEnv),
+	stringField("minio_endpoint", "localhost:9000", "MinIO server endpoint (e.g., localhost:9000)", func(c *Config) *string { return &c.MinIOEndpoint }),
+	stringField("minio_access_key", "minioadmin", "MinIO access key", func(c *Config) *string { return &c.MinIOAccessKey }),
+	stringField("minio_secret_key", "minioadmin", "MinIO secret key", func(c *Config) *string { return &c.MinIOSecretKey }),
+	stringField("minio_access_key_file", "", "File containing the MinIO access key", func(c *Config) *string { return &c.MinIOAccessKeyFile }),
+	stringField("minio_secret_key_file", "", "File containing the MinIO secret key", func(c *Config) *string { return &c.MinIOSecretKeyFile }),
+	boolField("minio_use_ssl", false, "Use SSL for MinIO connection", func(c *Config) *bool { return &c.MinIOUseSSL }),
+	stringField("listen_addr", ":8080", "Address to listen on (e.g., :8080)", func(c *Config) *string { return &c.ListenAddr }),
+	stringField("web_config_file", "", "Path to a Prometheus style web config file enabling TLS and basic auth", func(c *Config) *string { return &c.WebConfigFile }),
+	durationField("shutdown_timeout", 30*time.Second, "Time to drain in-flight requests on shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	durationField("readiness_max_age", 5*time.Minute, "Maximum discovery snapshot age for /-/ready (0 disables the check)", func(c *Config) *time.Duration { return &c.ReadinessMaxAge }),
+	durationField("scrape_interval", 15*time.Second, "Scrape interval", func(c *Config) *time.Duration { return &c.ScrapeInterval }),
+	durationField("scrape_timeout", 10*time.Second, "Scrape timeout, at most the scrape interval (the default is capped at it)", func(c *Config) *time.Duration { return &c.ScrapeTimeout }),
+	stringField("metrics_path", "/minio/metrics/v3", "Metrics path", func(c *Config) *string { return &c.MetricsPath }),
+	stringField("bucket_pattern", "*", "Wildcard pattern for bucket inclusion", func(c *Config) *string { return &c.BucketPattern }),
+	stringField("bucket_exclude_pattern", "", "Wildcard pattern for bucket exclusion", func(c *Config) *string { return &c.BucketExcludePattern }),
+	stringField("cluster_name", "", "Name of the MinIO cluster used in health and metrics output (defaults to the endpoint)", func(c *Config) *string { return &c.ClusterName }),
+	stringField("bucket_owner_tag", "owner", "Bucket tag holding the bucket owner", func(c *Config) *string { return &c.BucketOwnerTag }),
+	listField("sd_allowed_params", "", "Comma-separated /sd query parameters callers may use (bucket_pattern, exclude, cluster, pool, label.<name>, label.*)", func(c *Config) *[]string { return &c.SDAllowedParams }),
+	listField("shard_labels", "sd_bucket", "Comma-separated labels hashed to shard /sd target groups", func(c *Config) *[]string { return &c.ShardLabels }),
+	stringField("external_url", "", "URL Prometheus uses to reach this service (e.g., http://sd.example.com:8080)", func(c *Config) *string { return &c.ExternalURL }),
+	stringField("prometheus_sd_token_file", "", "Bearer token file referenced in the generated Prometheus config for /sd", func(c *Config) *string { return &c.PrometheusSDTokenFile }),
+	stringField("prometheus_minio_token_file", "", "Bearer token file referenced in the generated Prometheus config for MinIO metrics", func(c *Config) *string { return &c.PrometheusMinIOTokenFile }),
+	intField("vmagent_series_limit", 0, "series_limit set on the generated vmagent jobs", func(c *Config) *int { return &c.VMAgentSeriesLimit }),
+	stringField("telegraf_minio_token_file", "", "Bearer token file referenced in the generated Telegraf config for MinIO metrics", func(c *Config) *string { return &c.TelegrafMinIOTokenFile }),
+	stringField("alloy_forward_to", "prometheus.remote_write.default.receiver", "Receiver the generated Alloy scrape components forward to", func(c *Config) *string { return &c.AlloyForwardTo }),
+	stringField("file_sd_dir", "", "Directory to write file_sd target files to, one per job", func(c *Config) *string { return &c.FileSDDir }),
+	stringField("file_sd_format", FileSDFormatJSON, "Format of the file_sd target files", func(c *Config) *string { return &c.FileSDFormat }).oneOf(FileSDFormatJSON, FileSDFormatYAML),
+	stringField("consul_listen_addr", "", "Address for the emulated Consul catalog API (e.g., :8500)", func(c *Config) *string { return &c.ConsulListenAddr }),
+	stringField("dns_listen_addr", "", "UDP/TCP address for the embedded DNS responder (e.g., :5353)", func(c *Config) *string { return &c.DNSListenAddr }),
+	stringField("dns_domain", "sd.local", "Domain served by the embedded DNS responder", func(c *Config) *string { return &c.DNSDomain }),
+	durationField("server_info_timeout", 10*time.Second, "Timeout for MinIO admin ServerInfo calls", func(c *Config) *time.Duration { return &c.ServerInfoTimeout }),
+	durationField("list_buckets_timeout", 10*time.Second, "Timeout for MinIO ListBuckets calls", func(c *Config) *time.Duration { return &c.ListBucketsTimeout }),
+	durationField("enrichment_timeout", 5*time.Second, "Timeout for per-bucket metadata calls", func(c *Config) *time.Duration { return &c.EnrichmentTimeout }),
+	boolField("bucket_enrichment", true, "Fetch versioning and tags of every bucket on refresh", func(c *Config) *bool { return &c.BucketEnrichment }),
+	intField("enrichment_workers", 4, "Buckets whose metadata is fetched concurrently", func(c *Config) *int { return &c.EnrichmentWorkers }),
+	durationField("enrichment_deadline", 30*time.Second, "Overall time allowed for fetching bucket metadata per refresh (0 disables the limit)", func(c *Config) *time.Duration { return &c.EnrichmentDeadline }),
+	intField("breaker_failure_threshold", 3, "Consecutive MinIO failures before the circuit breaker opens", func(c *Config) *int { return &c.BreakerFailureThreshold }),
+	durationField("backoff_initial", 5*time.Second, "Initial circuit breaker backoff", func(c *Config) *time.Duration { return &c.BackoffInitial }),
+	durationField("backoff_max", 5*time.Minute, "Maximum circuit breaker backoff", func(c *Config) *time.Duration { return &c.BackoffMax }),
+	stringField("log_level", "info", "Log level", func(c *Config) *string { return &c.LogLevel }).oneOf("trace", "debug", "info", "warn", "warning", "error", "fatal", "panic"),
+}
+
+// registerConfigFlags adds a flag for every layered setting. Only flags given on the
+// command line override the other sources, see flagValues.
+func registerConfigFlags(fs *flag.FlagSet) {
+	for _, field := range configFields {
+		usage := fmt.Sprintf("%s (env %s)", field.usage, field.envName())
+		if field.isBool {
+			fs.Bool(field.flagName(), field.def == "true", usage)
+		} else {
+			fs.String(field.flagName(), field.def, usage)
+		}
+	}
+}
+
+// flagValues returns the settings given on the command line, keyed by config file key
+func flagValues(fs *flag.FlagSet) map[string]string {
+	keys := make(map[string]string, len(configFields))
+	for _, field := range configFields {
+		keys[field.flagName()] = field.key
+	}
+	values := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			values[key] = f.Value.String()
+		}
+	})
+	return values
+}
+
+// fileValues returns the settings present in a config file, in flag syntax, keyed by
+// config file key. Lists are joined with commas.
+func fileValues(fileConfig *ConfigFile) map[string]string {
+	values := make(map[string]string)
+	v := reflect.ValueOf(fileConfig).Elem()
+	for i := range v.NumField() {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Pointer && !field.IsNil():
+			values[key] = fmt.Sprint(field.Elem().Interface())
+		case field.Kind() == reflect.Slice && !field.IsNil():
+			values[key] = strings.Join(field.Interface().([]string), ",")
+		}
+	}
+	return values
+}
+
+// buildConfig layers the settings as defaults < config file < profile of the config file <
+// environment variables < command line flags, recording the source of each setting in
+// Config.Sources. Invalid values are reported with their source instead of being ignored.
+func buildConfig(fileConfig *ConfigFile, flags map[string]string) (Config, error) {
+	config := Config{Sources: make(map[string]string, len(configFields)+2)}
+	files := fileValues(fileConfig)
+	fileSource := func(key string) (string, string) {
+		if slices.Contains(fileConfig.profileKeys, key) {
+			return SourceProfile, "profile " + fileConfig.profile
+		}
+		return SourceFile, "config file"
+	}
+
+	var errs []error
+	for _, field := range configFields {
+		value, source, origin := field.def, SourceDefault, "default"
+		if fileValue, ok := files[field.key]; ok {
+			value = fileValue
+			source, origin = fileSource(field.key)
+		}
+		if envValue := os.Getenv(field.envName()); envValue != "" {
+			value, source, origin = envValue, SourceEnv, "$"+field.envName()
+		}
+		if flagValue, ok := flags[field.key]; ok {
+			value, source, origin = flagValue, SourceFlag, "-"+field.flagName()
+		}
+		if err := field.parse(&config, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", field.key, origin, err))
+		}
+		config.Sources[field.key] = source
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+
+	config.Auth = fileConfig.Auth
+	config.Sources["auth"] = SourceDefault
+	if !reflect.ValueOf(fileConfig.Auth).IsZero() {
+		config.Sources["auth"], _ = fileSource("auth")
+	}
+
+	jobs, err := parseJobConfigs(fileConfig.Jobs, "config file")
+	if err != nil {
+		return Config{}, err
+	}
+	config.Jobs = jobs
+	config.Sources["jobs"] = SourceDefault
+	if len(jobs) > 0 {
+		config.Sources["jobs"], _ = fileSource("jobs")
+	}
+
+	// Like Prometheus, a shorter interval caps the default timeout; a configured one must fit
+	if config.Sources["scrape_timeout"] == SourceDefault {
+		config.ScrapeTimeout = min(config.ScrapeTimeout, config.ScrapeInterval)
+	}
+
+	if err := resolveSecretFiles(&config); err != nil {
+		return Config{}, err
+	}
+
+	// Default the cluster name to the endpoint so single-cluster setups need no extra config
+	if config.ClusterName == "" {
+		config.ClusterName = config.MinIOEndpoint
+	}
+	return config, nil
+}
+
+// readSecretFile reads a credential from a file, such as a mounted Kubernetes or Docker
+// secret. Surrounding whitespace, including the trailing newline, is removed.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// sourceRank orders the sources by precedence
+func sourceRank(source string) int {
+	return slices.Index([]string{SourceDefault, SourceFile, SourceProfile, SourceEnv, SourceFlag}, source)
+}
+
+// resolveSecretFiles reads the credentials given as *_file settings. A credential set
+// directly in a higher layer wins over a file from a lower one; setting both in the same
+// layer is an error.
+func resolveSecretFiles(config *Config) error {
+	for _, secret := range []struct {
+		key         string
+		value, file *string
+	}{
+		{"minio_access_key", &config.MinIOAccessKey, &config.MinIOAccessKeyFile},
+		{"minio_secret_key", &config.MinIOSecretKey, &config.MinIOSecretKeyFile},
+	} {
+		if *secret.file == "" {
+			continue
+		}
+		fileKey := secret.key + "_file"
+		valueSource, fileSource := config.Sources[secret.key], config.Sources[fileKey]
+		switch {
+		case sourceRank(valueSource) > sourceRank(fileSource):
+			continue
+		case valueSource == fileSource:
+			return fmt.Errorf("%s and %s are both set (from %s)", secret.key, fileKey, fileSource)
+		}
+		value, err := readSecretFile(*secret.file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileKey, err)
+		}
+		*secret.value = value
+		config.Sources[secret.key] = fileSource
+	}
+
+	users, err := mergeBasicAuthUserFiles(config.Auth.BasicAuthUsers, config.Auth.BasicAuthUserFiles)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	config.Auth.BasicAuthUsers = users
+	return nil
+}
+
+// mergeBasicAuthUserFiles returns the basic auth users with the bcrypt hashes read from
+// basic_auth_user_files added. The users map is not modified.
+func mergeBasicAuthUserFiles(users, files map[string]string) (map[string]string, error) {
+	if len(files) == 0 {
+		return users, nil
+	}
+	merged := maps.Clone(users)
+	if merged == nil {
+		merged = make(map[string]string, len(files))
+	}
+	for user, file := range files {
+		if _, ok := merged[user]; ok {
+			return nil, fmt.Errorf("basic auth user %s is set in both basic_auth_users and basic_auth_user_files", user)
+		}
+		hash, err := readSecretFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth user %s: %w", user, err)
+		}
+		merged[user] = hash
+	}
+	return merged, nil
+}
+
+// secretFiles returns the credential files whose changes trigger a reload: MinIO keys,
+// basic auth password and bearer token files, and the web config file with the files it names
+func (c Config) secretFiles() []string {
+	var files []string
+	for _, file := range []string{c.MinIOAccessKeyFile, c.MinIOSecretKeyFile, c.WebConfigFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	for _, user := range slices.Sorted(maps.Keys(c.Auth.BasicAuthUserFiles)) {
+		files = append(files, c.Auth.BasicAuthUserFiles[user])
+	}
+	files = append(files, c.Auth.BearerTokenFiles...)
+	return append(files, c.WebConfigFiles...)
+}
+
+// envReferenceSyntax matches ${VAR} and ${VAR:-default} references
+const envReferenceSyntax = `\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`
+
+// envReference matches environment variable references; $${ escapes a literal ${
+var envReference = regexp.MustCompile(`\$\$\{|` + envReferenceSyntax)
+
+// expandEnv replaces environment variable references in a config value. ${VAR:-default}
+// falls back to default when VAR is unset or empty, and ${VAR} fails when VAR is unset.
+// Unlike os.ExpandEnv a bare $ is kept, so bcrypt hashes need no escaping.
+func expandEnv(value string) (string, error) {
+	var errs []error
+	expanded := envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+		match := envReference.FindStringSubmatch(reference)
+		name, hasDefault, def := match[1], match[2] != "", match[3]
+		value, ok := os.LookupEnv(name)
+		switch {
+		case hasDefault && value == "":
+			return def
+		case !ok:
+			errs = append(errs, fmt.Errorf("environment variable %s is not set", name))
+		}
+		return value
+	})
+	return expanded, errors.Join(errs...)
+}
+
+// expandEnvNode expands environment variable references in the values of a parsed YAML document
+func expandEnvNode(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		expanded, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if expanded != node.Value {
+			node.Value = expanded
+			if node.Style == 0 {
+				node.Tag = "" // Resolve plain scalars by their expanded value, e.g. ${PORT} as an integer
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandEnvNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := expandEnvNode(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// domainPattern matches DNS domain names
+var domainPattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.?$`)
+
+// validateAddress checks a host:port address. The host may be empty for listen addresses.
+func validateAddress(address string, requireHost bool) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if requireHost && host == "" {
+		return errors.New("missing host")
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// validateEndpoint checks a MinIO endpoint, given as host or host:port without scheme or path
+func validateEndpoint(endpoint string) error {
+	if strings.Contains(endpoint, "://") {
+		return errors.New("must not include a scheme, use minio_use_ssl for HTTPS")
+	}
+	if strings.Contains(endpoint, "/") {
+		return errors.New("must not include a path")
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		// Without a port, the default port of the scheme is used
+		if !domainPattern.MatchString(endpoint) && net.ParseIP(endpoint) == nil {
+			return fmt.Errorf("invalid host %q", endpoint)
+		}
+		return nil
+	}
+	return validateAddress(endpoint, true)
+}
+
+// validateConfig checks a configuration before it is put into effect, reporting every
+// problem at once
+func validateConfig(config Config) error {
+	var errs []error
+	problem := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if config.MinIOEndpoint == "" {
+		problem("minio_endpoint", "must not be empty")
+	} else if err := validateEndpoint(config.MinIOEndpoint); err != nil {
+		problem("minio_endpoint", "invalid endpoint %q: %v", config.MinIOEndpoint, err)
+	}
+	for key, address := range map[string]string{
+		"listen_addr":        config.ListenAddr,
+		"consul_listen_addr": config.ConsulListenAddr,
+		"dns_listen_addr":    config.DNSListenAddr,
+	} {
+		if address == "" && key != "listen_addr" {
+			continue
+		}
+		if err := validateAddress(address, false); err != nil {
+			problem(key, "invalid address %q: %v", address, err)
+		}
+	}
+	if config.ExternalURL != "" {
+		if u, err := url.Parse(config.ExternalURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("external_url", "must be an absolute http or https URL, got %q", config.ExternalURL)
+		}
+	}
+	if !strings.HasPrefix(config.MetricsPath, "/") {
+		problem("metrics_path", "must start with /, got %q", config.MetricsPath)
+	}
+
+	if config.ScrapeInterval <= 0 {
+		problem("scrape_interval", "must be positive, got %v", config.ScrapeInterval)
+	}
+	if config.ScrapeTimeout <= 0 {
+		problem("scrape_timeout", "must be positive, got %v", config.ScrapeTimeout)
+	} else if config.ScrapeTimeout > config.ScrapeInterval {
+		problem("scrape_timeout", "must not be longer than scrape_interval (%v), got %v", config.ScrapeInterval, config.ScrapeTimeout)
+	}
+	for job, overrides := range config.Jobs {
+		key := "jobs." + job
+		if !slices.Contains(scrapeJobs, job) {
+			problem(key, "unknown job, expected one of %s", strings.Join(scrapeJobs, ", "))
+			continue
+		}
+		if overrides.MetricsPath != "" && !strings.HasPrefix(overrides.MetricsPath, "/") {
+			problem(key+".metrics_path", "must start with /, got %q", overrides.MetricsPath)
+		}
+		if settings := config.jobSettings(job); settings.ScrapeTimeout > settings.ScrapeInterval {
+			problem(key+".scrape_timeout", "must not be longer than the scrape interval of the job (%v), got %v", settings.ScrapeInterval, settings.ScrapeTimeout)
+		}
+	}
+	for key, timeout := range map[string]time.Duration{
+		"shutdown_timeout":     config.ShutdownTimeout,
+		"readiness_max_age":    config.ReadinessMaxAge,
+		"server_info_timeout":  config.ServerInfoTimeout,
+		"list_buckets_timeout": config.ListBucketsTimeout,
+		"enrichment_timeout":   config.EnrichmentTimeout,
+		"enrichment_deadline":  config.EnrichmentDeadline,
+		"backoff_initial":      config.BackoffInitial,
+		"backoff_max":          config.BackoffMax,
+	} {
+		if timeout < 0 {
+			problem(key, "must not be negative, got %v", timeout)
+		}
+	}
+	if config.BackoffMax < config.BackoffInitial {
+		problem("backoff_max", "must not be shorter than backoff_initial (%v), got %v", config.BackoffInitial, config.BackoffMax)
+	}
+	if config.BreakerFailureThreshold < 1 {
+		problem("breaker_failure_threshold", "must be at least 1, got %d", config.BreakerFailureThreshold)
+	}
+	if config.BucketEnrichment && config.EnrichmentWorkers < 1 {
+		problem("enrichment_workers", "must be at least 1, got %d", config.EnrichmentWorkers)
+	}
+	if config.VMAgentSeriesLimit < 0 {
+		problem("vmagent_series_limit", "must not be negative, got %d", config.VMAgentSeriesLimit)
+	}
+
+	for key, pattern := range map[string]string{
+		"bucket_pattern":         config.BucketPattern,
+		"bucket_exclude_pattern": config.BucketExcludePattern,
+	} {
+		if _, err := regexp.Compile(globRegexp(pattern)); err != nil {
+			problem(key, "invalid pattern %q: %v", pattern, err)
+		}
+	}
+	if config.FileSDFormat != FileSDFormatJSON && config.FileSDFormat != FileSDFormatYAML {
+		problem("file_sd_format", "must be %s or %s, got %q", FileSDFormatJSON, FileSDFormatYAML, config.FileSDFormat)
+	}
+	if len(config.ShardLabels) == 0 {
+		problem("shard_labels", "must not be empty")
+	}
+	targetLabels := targetLabelNames(config)
+	for _, label := range config.ShardLabels {
+		if !labelNamePattern.MatchString(label) {
+			problem("shard_labels", "invalid label name %q", label)
+		} else if !slices.Contains(targetLabels, label) {
+			problem("shard_labels", "label %q is not set on any target group, expected one of %s", label, strings.Join(targetLabels, ", "))
+		}
+	}
+	for _, param := range config.SDAllowedParams {
+		label, isLabel := strings.CutPrefix(param, ScopeParamLabelPrefix)
+		switch {
+		case isLabel && label != "*" && !labelNamePattern.MatchString(label):
+			problem("sd_allowed_params", "invalid label name %q in %q", label, param)
+		case !isLabel && !slices.Contains(scopeParams, param):
+			problem("sd_allowed_params", "unknown parameter %q", param)
+		}
+	}
+	if config.DNSListenAddr != "" && !domainPattern.MatchString(config.DNSDomain) {
+		problem("dns_domain", "invalid domain %q", config.DNSDomain)
+	}
+	if config.LogLevel != "" {
+		if _, err := logrus.ParseLevel(config.LogLevel); err != nil {
+			problem("log_level", "%v", err)
+		}
+	}
+	if config.Auth.Enabled() {
+		if _, err := NewAuthenticator(config.Auth); err != nil {
+			problem("auth", "%v", err)
+		}
+		for _, message := range config.Auth.unbackedMethods(config.TLSServer.verifiesClientCerts()) {
+			problem("auth", "%s", message)
+		}
+	}
+
+	// Map iteration order is random; report problems in a stable order
+	slices.SortStableFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+// checkConfig runs every check applied at startup without connecting to MinIO, as done by
+// the check-config command
+func checkConfig(config Config) error {
+	if _, err := applyWebConfig(&config); err != nil {
+		return fmt.Errorf("web_config_file: %w", err)
+	}
+	return validateConfig(config)
+}
+
+// knownKeys returns the YAML keys of a struct type
+func knownKeys(t reflect.Type) map[string]reflect.Type {
+	keys := make(map[string]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if key, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); key != "" && key != "-" {
+			keys[key] = field.Type
+		}
+	}
+	return keys
+}
+
+// closestKey returns the known key with the smallest edit distance to key, if it is close enough to be a typo
+func closestKey(key string, known map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for candidate := range known {
+		if distance := editDistance(key, candidate); distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// checkKnownKeys reports the mapping keys of a YAML node that do not correspond to a field
+// of t, with their line number and the closest known key
+func checkKnownKeys(node *yaml.Node, t reflect.Type) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var errs []error
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		known := knownKeys(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := known[key.Value]
+			if !ok {
+				err := fmt.Errorf("line %d: unknown key %q", key.Line, key.Value)
+				if suggestion := closestKey(key.Value, known); suggestion != "" {
+					err = fmt.Errorf("%w, did you mean %q?", err, suggestion)
+				}
+				errs = append(errs, err)
+				continue
+			}
+			errs = append(errs, checkKnownKeys(value, fieldType)...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, checkKnownKeys(node.Content[i], t.Elem())...)
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range node.Content {
+			errs = append(errs, checkKnownKeys(item, t.Elem())...)
+		}
+	}
+	return errs
+}
+
+// settingSource returns the source of an effective configuration setting
+func (c Config) settingSource(key string) string {
+	if strings.HasPrefix(key, "auth.") {
+		key = "auth"
+	}
+	if job, ok := strings.CutPrefix(key, "jobs."); ok {
+		job, setting, _ := strings.Cut(job, ".")
+		return c.jobSettingSource(job, setting)
+	}
+	return c.Sources[key]
+}
+
+// printConfigSettings writes the effective configuration with the source of each setting
+// and credentials masked, as shown by -print-config
+func printConfigSettings(w io.Writer, config Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, setting := range effectiveConfig(config) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseConfigFile(t *testing.T, content string) *ConfigFile {
+	t.Helper()
+	var fileConfig ConfigFile
+	if err := yaml.Unmarshal([]byte(content), &fileConfig); err != nil {
+		t.Fatalf("Failed to parse config file: %v", err)
+	}
+	return &fileConfig
+}
+
+func TestBuildConfigPrecedence(t *testing.T) {
+	fileConfig := parseConfigFile(t, `
+minio_endpoint: "file:9000"
+bucket_pattern: "file-*"
+scrape_interval: "30s"
+listen_addr: ":7070"
+`)
+	t.Setenv("BUCKET_PATTERN", "env-*")
+	t.Setenv("LISTEN_ADDR", ":6060")
+	flags := map[string]string{"listen_addr": ":5050"}
+
+	config, err := buildConfig(fileConfig, flags)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		key, value, got, source string
+	}{
+		{"metrics_path", "/minio/metrics/v3", config.MetricsPath, SourceDefault},
+		{"minio_endpoint", "file:9000", config.MinIOEndpoint, SourceFile},
+		{"bucket_pattern", "env-*", config.BucketPattern, SourceEnv},
+		{"listen_addr", ":5050", config.ListenAddr, SourceFlag},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.value {
+			t.Errorf("Expected %s %q, got %q", tt.key, tt.value, tt.got)
+		}
+		if got := config.Sources[tt.key]; got != tt.source {
+			t.Errorf("Expected %s from %s, got %s", tt.key, tt.source, got)
+		}
+	}
+	if config.ScrapeInterval != 30*time.Second {
+		t.Errorf("Expected scrape interval 30s, got %v", config.ScrapeInterval)
+	}
+	if config.ClusterName != "file:9000" {
+		t.Errorf("Expected the cluster name to default to the endpoint, got %q", config.ClusterName)
+	}
+}
+
+func TestBuildConfigExplicitFalse(t *testing.T) {
+	// false in the file and on the command line overrides a true from a lower layer
+	t.Setenv("MINIO_USE_SSL", "true")
+	config, err := buildConfig(&ConfigFile{}, map[string]string{"minio_use_ssl": "false"})
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if config.MinIOUseSSL {
+		t.Error("Expected -minio-use-ssl=false to override MINIO_USE_SSL=true")
+	}
+
+	t.Setenv("MINIO_USE_SSL", "")
+	fileConfig := parseConfigFile(t, "minio_use_ssl: false\nbreaker_failure_threshold: 0\n")
+	config, err = buildConfig(fileConfig, nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if config.Sources["minio_use_ssl"] != SourceFile {
+		t.Errorf("Expected minio_use_ssl: false to come from the file, got %s", config.Sources["minio_use_ssl"])
+	}
+	if config.BreakerFailureThreshold != 0 || config.Sources["breaker_failure_threshold"] != SourceFile {
+		t.Errorf("Expected breaker_failure_threshold 0 from the file, got %d from %s", config.BreakerFailureThreshold, config.Sources["breaker_failure_threshold"])
+	}
+}
+
+func TestBuildConfigScrapeTimeout(t *testing.T) {
+	// The default timeout is capped at a shorter interval
+	config, err := buildConfig(parseConfigFile(t, `scrape_interval: "5s"`), nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if config.ScrapeTimeout != 5*time.Second {
+		t.Errorf("Expected the default timeout capped at 5s, got %v", config.ScrapeTimeout)
+	}
+
+	// A configured one is kept and rejected by validation
+	config, err = buildConfig(parseConfigFile(t, "scrape_interval: \"5s\"\nscrape_timeout: \"10s\"\n"), nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "scrape_timeout: must not be longer than scrape_interval (5s), got 10s") {
+		t.Errorf("Expected a scrape_timeout error, got %v", err)
+	}
+
+	config, err = buildConfig(parseConfigFile(t, "jobs:\n  minio-buckets:\n    scrape_interval: 1m\n    metrics_path: /minio/metrics/v3/bucket/replication\n"), nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if got := config.Jobs["minio-buckets"]; got.ScrapeInterval != time.Minute || got.MetricsPath != "/minio/metrics/v3/bucket/replication" {
+		t.Errorf("Expected the minio-buckets overrides, got %+v", got)
+	}
+	if config.Sources["jobs"] != SourceFile {
+		t.Errorf("Expected jobs to come from the file, got %s", config.Sources["jobs"])
+	}
+}
+
+func TestBuildConfigInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		flags   map[string]string
+		message string
+	}{
+		{"file duration", `scrape_interval: "15 seconds"`, nil, nil, `scrape_interval (from config file): invalid duration "15 seconds"`},
+		{"env duration", "", map[string]string{"BACKOFF_MAX": "5 minutes"}, nil, `backoff_max (from $BACKOFF_MAX): invalid duration "5 minutes"`},
+		{"env boolean", "", map[string]string{"MINIO_USE_SSL": "invalid"}, nil, `minio_use_ssl (from $MINIO_USE_SSL): invalid boolean "invalid"`},
+		{"flag integer", "", nil, map[string]string{"vmagent_series_limit": "many"}, `vmagent_series_limit (from -vmagent-series-limit): invalid integer "many"`},
+		{"job duration", "jobs:\n  minio-buckets:\n    scrape_interval: 5 minutes\n", nil, nil, `jobs.minio-buckets.scrape_interval (from config file): invalid duration "5 minutes"`},
+		{"job zero duration", "jobs:\n  minio-server:\n    scrape_timeout: 0s\n", nil, nil, `jobs.minio-server.scrape_timeout (from config file): must be positive`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := buildConfig(parseConfigFile(t, tt.file), tt.flags)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %q", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestPrintConfigSettings(t *testing.T) {
+	fileConfig := parseConfigFile(t, `minio_secret_key: "supersecret"`)
+	config, err := buildConfig(fileConfig, map[string]string{"bucket_pattern": "prod-*"})
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := printConfigSettings(&out, config); err != nil {
+		t.Fatalf("printConfigSettings failed: %v", err)
+	}
+	if strings.Contains(out.String(), "supersecret") {
+		t.Errorf("Expected the secret key to be masked:\n%s", out.String())
+	}
+	for _, want := range [][]string{
+		{"minio_secret_key", "su***et", SourceFile},
+		{"bucket_pattern", "prod-*", SourceFlag},
+		{"listen_addr", ":8080", SourceDefault},
+	} {
+		found := false
+		for _, line := range strings.Split(out.String(), "\n") {
+			if fields := strings.Fields(line); len(fields) == 3 && fields[0] == want[0] {
+				found = fields[1] == want[1] && fields[2] == want[2]
+			}
+		}
+		if !found {
+			t.Errorf("Expected line %v in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("SD_TEST_HOST", "minio.example.com")
+	t.Setenv("SD_TEST_EMPTY", "")
+
+	tests := []struct {
+		value, expected string
+		fails           bool
+	}{
+		{"${SD_TEST_HOST}:9000", "minio.example.com:9000", false},
+		{"${SD_TEST_UNSET:-localhost}:9000", "localhost:9000", false},
+		{"${SD_TEST_EMPTY:-fallback}", "fallback", false},
+		{"${SD_TEST_EMPTY}", "", false},
+		{"$${SD_TEST_HOST}", "${SD_TEST_HOST}", false},
+		{"$2y$10$abcdefghijklmnopqrstuv", "$2y$10$abcdefghijklmnopqrstuv", false},
+		{"${SD_TEST_UNSET}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.value)
+		if (err != nil) != tt.fails {
+			t.Errorf("expandEnv(%q): unexpected error state: %v", tt.value, err)
+			continue
+		}
+		if !tt.fails && got != tt.expected {
+			t.Errorf("expandEnv(%q): expected %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadConfigFromFileExpandsEnv(t *testing.T) {
+	t.Setenv("SD_TEST_THRESHOLD", "7")
+	t.Setenv("SD_TEST_PATTERN", "prod-*")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `bucket_pattern: "${SD_TEST_PATTERN}"
+breaker_failure_threshold: ${SD_TEST_THRESHOLD}
+minio_use_ssl: ${SD_TEST_SSL:-true}
+shard_labels: ["${SD_TEST_LABEL:-sd_bucket}", "pool"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	fileConfig, err := loadConfigFromFile(path, "")
+	if err != nil {
+		t.Fatalf("loadConfigFromFile failed: %v", err)
+	}
+	if *fileConfig.BucketPattern != "prod-*" {
+		t.Errorf("Expected bucket pattern prod-*, got %q", *fileConfig.BucketPattern)
+	}
+	if *fileConfig.BreakerFailureThreshold != 7 {
+		t.Errorf("Expected breaker threshold 7, got %d", *fileConfig.BreakerFailureThreshold)
+	}
+	if !*fileConfig.MinIOUseSSL {
+		t.Error("Expected minio_use_ssl to default to true")
+	}
+	if !slices.Equal(fileConfig.ShardLabels, []string{"sd_bucket", "pool"}) {
+		t.Errorf("Expected shard labels [sd_bucket pool], got %v", fileConfig.ShardLabels)
+	}
+
+	if err := os.WriteFile(path, []byte("minio_endpoint: localhost:9000\nminio_secret_key: ${SD_TEST_UNSET}\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	_, err = loadConfigFromFile(path, "")
+	if err == nil || !strings.Contains(err.Error(), "line 2: environment variable SD_TEST_UNSET is not set") {
+		t.Errorf("Expected an error for the unset variable on line 2, got %v", err)
+	}
+}
+
+func TestResolveSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret-key")
+	hashFile := filepath.Join(dir, "alice.hash")
+	hash := "$2y$10$mDwo.lAisC94iLAyP81MCesa29IzH37oigHC/42V2pdJlUprsJPze"
+	for path, content := range map[string]string{secretFile: "s3cr3t-from-file\n", hashFile: hash + "\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	t.Run("file", func(t *testing.T) {
+		fileConfig := parseConfigFile(t, fmt.Sprintf("minio_secret_key_file: %q\nauth:\n  basic_auth_user_files:\n    alice: %q\n", secretFile, hashFile))
+		config, err := buildConfig(fileConfig, nil)
+		if err != nil {
+			t.Fatalf("buildConfig failed: %v", err)
+		}
+		if config.MinIOSecretKey != "s3cr3t-from-file" {
+			t.Errorf("Expected the secret key from the file, got %q", config.MinIOSecretKey)
+		}
+		if config.Sources["minio_secret_key"] != SourceFile {
+			t.Errorf("Expected minio_secret_key from file, got %s", config.Sources["minio_secret_key"])
+		}
+		if config.Auth.BasicAuthUsers["alice"] != hash {
+			t.Errorf("Expected alice's hash from the file, got %q", config.Auth.BasicAuthUsers["alice"])
+		}
+		if !slices.Equal(config.secretFiles(), []string{secretFile, hashFile}) {
+			t.Errorf("Expected secret files [%s %s], got %v", secretFile, hashFile, config.secretFiles())
+		}
+	})
+
+	t.Run("higher layer wins", func(t *testing.T) {
+		fileConfig := parseConfigFile(t, fmt.Sprintf("minio_secret_key_file: %q\n", secretFile))
+		config, err := buildConfig(fileConfig, map[string]string{"minio_secret_key": "from-flag"})
+		if err != nil {
+			t.Fatalf("buildConfig failed: %v", err)
+		}
+		if config.MinIOSecretKey != "from-flag" {
+			t.Errorf("Expected the flag to win over the file, got %q", config.MinIOSecretKey)
+		}
+	})
+
+	t.Run("same layer conflict", func(t *testing.T) {
+		fileConfig := parseConfigFile(t, fmt.Sprintf("minio_secret_key: inline\nminio_secret_key_file: %q\n", secretFile))
+		if _, err := buildConfig(fileConfig, nil); err == nil {
+			t.Error("Expected an error when both minio_secret_key and minio_secret_key_file are set")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("MINIO_ACCESS_KEY_FILE", filepath.Join(dir, "missing"))
+		_, err := buildConfig(&ConfigFile{}, nil)
+		if err == nil || !strings.Contains(err.Error(), "minio_access_key_file") {
+			t.Errorf("Expected an error naming minio_access_key_file, got %v", err)
+		}
+	})
+}
+
+func TestLoadConfigFromFileRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `minio_endpoint: "localhost:9000"
+bucket_exclude_patern: "*tmp*"
+auth:
+  basic_auth_users: {}
+  default_polcy: ["basic"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	_, err := loadConfigFromFile(path, "")
+	if err == nil {
+		t.Fatal("Expected an error for unknown keys")
+	}
+	for _, want := range []string{
+		`line 2: unknown key "bucket_exclude_patern", did you mean "bucket_exclude_pattern"?`,
+		`line 5: unknown key "default_polcy", did you mean "default_policy"?`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid, err := buildConfig(&ConfigFile{}, nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if err := validateConfig(valid); err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(config *Config)
+		message string
+	}{
+		{"endpoint with scheme", func(c *Config) { c.MinIOEndpoint = "https://minio:9000" }, "minio_endpoint: invalid endpoint"},
+		{"endpoint with path", func(c *Config) { c.MinIOEndpoint = "minio:9000/data" }, "must not include a path"},
+		{"endpoint port", func(c *Config) { c.MinIOEndpoint = "minio:http" }, `invalid port "http"`},
+		{"listen address", func(c *Config) { c.ListenAddr = "8080" }, "listen_addr: invalid address"},
+		{"external url", func(c *Config) { c.ExternalURL = "sd.example.com:8080" }, "external_url: must be an absolute http or https URL"},
+		{"metrics path", func(c *Config) { c.MetricsPath = "metrics" }, "metrics_path: must start with /"},
+		{"scrape interval", func(c *Config) { c.ScrapeInterval = 0 }, "scrape_interval: must be positive"},
+		{"backoff order", func(c *Config) { c.BackoffMax = time.Second }, "backoff_max: must not be shorter than backoff_initial"},
+		{"breaker threshold", func(c *Config) { c.BreakerFailureThreshold = 0 }, "breaker_failure_threshold: must be at least 1"},
+		{"shard label", func(c *Config) { c.ShardLabels = []string{"sd-bucket"} }, `shard_labels: invalid label name "sd-bucket"`},
+		{"route policy without credentials", func(c *Config) { c.Auth.RoutePolicies = map[string][]string{"/sd": {AuthMethodBearer}} }, "auth: route_policies./sd: method bearer requires bearer_token_files or bearer_token_sha256"},
+		{"shard label not emitted", func(c *Config) { c.ShardLabels = []string{"cluster"} }, `shard_labels: label "cluster" is not set on any target group, expected one of __address__, __metrics_path__`},
+		{"scope label", func(c *Config) { c.SDAllowedParams = []string{"label.team-name"} }, `sd_allowed_params: invalid label name "team-name"`},
+		{"scope parameter", func(c *Config) { c.SDAllowedParams = []string{"bucket"} }, `sd_allowed_params: unknown parameter "bucket"`},
+		{"dns domain", func(c *Config) { c.DNSListenAddr, c.DNSDomain = ":5353", "sd..local" }, `dns_domain: invalid domain "sd..local"`},
+		{"log level", func(c *Config) { c.LogLevel = "verbose" }, "log_level: "},
+		{"scrape timeout", func(c *Config) { c.ScrapeTimeout = time.Minute }, "scrape_timeout: must not be longer than scrape_interval"},
+		{"unknown job", func(c *Config) { c.Jobs = map[string]JobSettings{"minio-bucket": {}} }, "jobs.minio-bucket: unknown job"},
+		{"job metrics path", func(c *Config) { c.Jobs = map[string]JobSettings{"minio-server": {MetricsPath: "metrics"}} }, "jobs.minio-server.metrics_path: must start with /"},
+		{"job timeout", func(c *Config) { c.Jobs = map[string]JobSettings{"minio-server": {ScrapeTimeout: 20 * time.Second}} }, "jobs.minio-server.scrape_timeout: must not be longer than the scrape interval of the job (15s), got 20s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			err := validateConfig(config)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+
+	// Every problem is reported at once
+	config := valid
+	config.MetricsPath, config.BreakerFailureThreshold = "", 0
+	if err := validateConfig(config); err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("Expected two problems, got %v", err)
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	fileConfig, err := loadConfigFromFile("config.yaml.example", "")
+	if err != nil {
+		t.Fatalf("Failed to load config.yaml.example: %v", err)
+	}
+	config, err := buildConfig(fileConfig, nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if err := checkConfig(config); err != nil {
+		t.Errorf("Expected config.yaml.example to be valid, got %v", err)
+	}
+}
+
+func TestCheckConfigRejectsUnknownWebConfigKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.yml")
+	if err := os.WriteFile(path, []byte("tls_server_config:\n  cert_fil: /etc/tls/tls.crt\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write web config file: %v", err)
+	}
+	fileConfig := parseConfigFile(t, fmt.Sprintf("minio_endpoint: localhost:9000\nweb_config_file: %q\n", path))
+	config, err := buildConfig(fileConfig, nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	err = checkConfig(config)
+	if err == nil || !strings.Contains(err.Error(), "web_config_file: ") || !strings.Contains(err.Error(), "field cert_fil not found") {
+		t.Errorf("Expected an error for the misspelled web config key, got %v", err)
+	}
+}
+
+func TestCheckConfigRejectsDuplicateWebConfigUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.yml")
+	if err := os.WriteFile(path, []byte("basic_auth_users:\n  prometheus: $2y$10$web\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write web config file: %v", err)
+	}
+	fileConfig := parseConfigFile(t, fmt.Sprintf("minio_endpoint: localhost:9000\nweb_config_file: %q\nauth:\n  basic_auth_users:\n    prometheus: $2y$10$auth\n", path))
+	config, err := buildConfig(fileConfig, nil)
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	err = checkConfig(config)
+	if err == nil || !strings.Contains(err.Error(), "basic auth user prometheus is set in both the web config file and the auth section") {
+		t.Errorf("Expected an error for the duplicate basic auth user, got %v", err)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := ConfigSchema()
+	properties := schema["properties"].(map[string]any)
+	for key := range knownKeys(reflect.TypeFor[ConfigFile]()) {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("Expected config file key %s in the schema", key)
+		}
+	}
+	authProperties := properties["auth"].(map[string]any)["properties"].(map[string]any)
+	for key := range knownKeys(reflect.TypeFor[AuthConfig]()) {
+		if _, ok := authProperties[key]; !ok {
+			t.Errorf("Expected auth key %s in the schema", key)
+		}
+	}
+
+	// The committed schema used by editors must match the generated one
+	var generated bytes.Buffer
+	if err := writeConfigSchema(&generated); err != nil {
+		t.Fatalf("writeConfigSchema failed: %v", err)
+	}
+	committed, err := os.ReadFile("config.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read config.schema.json: %v", err)
+	}
+	if !bytes.Equal(committed, generated.Bytes()) {
+		t.Error("config.schema.json is out of date, regenerate it with: go run . config-schema > config.schema.json")
+	}
+}
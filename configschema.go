@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// jsonSchemaDraft is the JSON Schema version of the generated config file schema, the one
+// most widely supported by editors (e.g. through yaml-language-server)
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// allowEnvReference extends the schema of a non-string value, or of a string restricted by
+// a pattern or enum, to also accept a ${VAR} reference expanded when the file is loaded
+func allowEnvReference(schema map[string]any) map[string]any {
+	_, hasPattern := schema["pattern"]
+	_, hasEnum := schema["enum"]
+	if schema["type"] == "array" || (schema["type"] == "string" && !hasPattern && !hasEnum) {
+		return schema
+	}
+	wrapped := map[string]any{
+		"anyOf": []any{schema, map[string]any{"type": "string", "pattern": envReferenceSyntax}},
+	}
+	for _, key := range []string{"description", "default"} {
+		if value, ok := schema[key]; ok {
+			wrapped[key] = value
+			delete(schema, key)
+		}
+	}
+	return wrapped
+}
+
+// defaultValue returns the default of a setting as a JSON value, or nil if it has none
+func (f configField) defaultValue() any {
+	switch f.schema["type"] {
+	case "boolean":
+		return f.def == "true"
+	case "integer":
+		value, _ := strconv.Atoi(f.def)
+		return value
+	case "array":
+		if f.def == "" {
+			return nil
+		}
+		return strings.Split(f.def, ",")
+	default:
+		if f.def == "" {
+			return nil
+		}
+		return f.def
+	}
+}
+
+// authSchema returns the schema of the auth section
+func authSchema() map[string]any {
+	stringList := func(description string) map[string]any {
+		return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": description}
+	}
+	stringMap := func(description string) map[string]any {
+		return map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "description": description}
+	}
+	methods := map[string]any{
+		"type":  "array",
+		"items": map[string]any{"type": "string", "enum": []string{AuthMethodNone, AuthMethodBasic, AuthMethodBearer, AuthMethodClientCert}},
+	}
+
+	defaultPolicy := maps.Clone(methods)
+	defaultPolicy["description"] = "Methods accepted on routes without an explicit policy; defaults to every configured method"
+	return map[string]any{
+		"type":                 "object",
+		"description":          "Authentication for the HTTP endpoints (config file only)",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"basic_auth_users":        stringMap("User names mapped to bcrypt password hashes"),
+			"basic_auth_user_files":   stringMap("User names mapped to files containing a bcrypt password hash"),
+			"bearer_token_files":      stringList("Files each containing one accepted bearer token"),
+			"bearer_token_sha256":     stringList("Hex-encoded SHA-256 hashes of accepted bearer tokens"),
+			"client_cert_allowed_cns": stringList("Subject common names accepted from verified client certificates; empty accepts any"),
+			"default_policy":          defaultPolicy,
+			"route_policies": map[string]any{
+				"type":                 "object",
+				"additionalProperties": methods,
+				"description":          `Route paths (e.g. /sd) mapped to the methods accepted on them; "none" leaves a route open`,
+			},
+		},
+	}
+}
+
+// jobsSchema returns the schema of the per-job overrides
+func jobsSchema() map[string]any {
+	duration := func(description string) map[string]any {
+		return allowEnvReference(map[string]any{"type": "string", "pattern": durationPattern, "description": description})
+	}
+	job := map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"scrape_interval": duration("Scrape interval of the job"),
+			"scrape_timeout":  duration("Scrape timeout of the job, at most its scrape interval"),
+			"metrics_path":    map[string]any{"type": "string", "description": "Metrics path of the job; bucket targets append the bucket name"},
+		},
+	}
+	properties := make(map[string]any, len(scrapeJobs))
+	for _, name := range scrapeJobs {
+		properties[name] = job
+	}
+	return map[string]any{
+		"type":                 "object",
+		"description":          "Scrape settings of individual jobs, overriding the global ones (config file only)",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+// ConfigSchema returns the JSON Schema of the config file, generated from the settings table
+func ConfigSchema() map[string]any {
+	properties := make(map[string]any, len(configFields)+3)
+	for _, field := range configFields {
+		schema := maps.Clone(field.schema)
+		schema["description"] = field.usage
+		if field.enum != nil {
+			schema["enum"] = field.enum
+		}
+		if def := field.defaultValue(); def != nil {
+			schema["default"] = def
+		}
+		properties[field.key] = allowEnvReference(schema)
+	}
+	properties["auth"] = authSchema()
+	properties["jobs"] = jobsSchema()
+
+	// A profile takes the same settings, except for selecting or defining profiles
+	profileProperties := maps.Clone(properties)
+	delete(profileProperties, "profile")
+	properties["profiles"] = map[string]any{
+		"type":        "object",
+		"description": "Named profiles merged into the settings above when selected with -profile, $EOS_SD_PROFILE or profile",
+		"additionalProperties": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           profileProperties,
+		},
+	}
+
+	return map[string]any{
+		"$schema":              jsonSchemaDraft,
+		"title":                "MinIO Prometheus Service Discovery configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// writeConfigSchema writes the JSON Schema of the config file, as printed by the config-schema command
+func writeConfigSchema(w io.Writer) error {
+	data, err := json.MarshalIndent(ConfigSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Blocking query limits, matching the Consul defaults
+const (
+	consulDefaultWait = 5 * time.Minute
+	consulMaxWait     = 10 * time.Minute
+)
+
+// ConsulServiceInstance is a target presented as an instance of a Consul service
+type ConsulServiceInstance struct {
+	ID      string
+	Node    string
+	Address string
+	Port    int
+	Tags    []string
+	Meta    map[string]string
+	Status  string // Aggregated health check status: passing or critical
+}
+
+// ConsulCatalog emulates the subset of the Consul catalog and health HTTP API used by
+// Prometheus consul_sd_configs. Every job is a service and every target of a target
+// group is a service instance; labels are exposed as service meta and name=value tags.
+type ConsulCatalog struct {
+	m *MinIOClient
+
+	mu       sync.Mutex
+	index    uint64
+	services map[string][]ConsulServiceInstance
+	changed  chan struct{} // Closed and replaced whenever the index changes
+	done     chan struct{} // Closed when Run returns, releasing blocked queries
+}
+
+// NewConsulCatalog creates a catalog fed from the discovery snapshot of m
+func NewConsulCatalog(m *MinIOClient) *ConsulCatalog {
+	return &ConsulCatalog{
+		m:        m,
+		index:    1,
+		services: map[string][]ConsulServiceInstance{},
+		changed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// consulMetaKey turns a target label into a valid Consul meta key (__metrics_path__ becomes metrics_path)
+func consulMetaKey(label string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(label, "__"), "__")
+}
+
+// consulServices converts the target groups of every job into Consul service instances
+func (c *ConsulCatalog) consulServices(ctx context.Context, snapshot *Snapshot) (map[string][]ConsulServiceInstance, error) {
+	configs, err := c.m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeStates := make(map[string]string, len(snapshot.Nodes))
+	for _, node := range snapshot.Nodes {
+		nodeStates[node.Endpoint] = node.State
+	}
+
+	services := make(map[string][]ConsulServiceInstance, len(configs))
+	for _, config := range configs {
+		instances := []ConsulServiceInstance{}
+		for _, group := range c.m.buildTargetGroups(config, snapshot, c.m.bucketFilter()) {
+			meta := make(map[string]string, len(group.Labels))
+			var tags []string
+			for name, value := range group.Labels {
+				meta[consulMetaKey(name)] = value
+				if !strings.HasPrefix(name, "__") {
+					tags = append(tags, name+"="+value)
+				}
+			}
+			slices.Sort(tags)
+
+			for _, target := range group.Targets {
+				host, portValue, err := net.SplitHostPort(target)
+				if err != nil {
+					logrus.Debugf("Skipping Consul instance for target %s: %v", target, err)
+					continue
+				}
+				port, err := strconv.Atoi(portValue)
+				if err != nil {
+					logrus.Debugf("Skipping Consul instance for target %s: invalid port", target)
+					continue
+				}
+
+				id := config.JobName + "-" + target
+				if bucket, ok := group.Labels["sd_bucket"]; ok {
+					id += "-" + bucket
+				}
+				status := "passing"
+				if state, ok := nodeStates[target]; ok && state != "online" {
+					status = "critical"
+				}
+				instances = append(instances, ConsulServiceInstance{
+					ID:      id,
+					Node:    host,
+					Address: host,
+					Port:    port,
+					Tags:    tags,
+					Meta:    meta,
+					Status:  status,
+				})
+			}
+		}
+		services[config.JobName] = instances
+	}
+	return services, nil
+}
+
+// update replaces the catalog content, bumping the index and waking blocked queries if it changed
+func (c *ConsulCatalog) update(services map[string][]ConsulServiceInstance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if reflect.DeepEqual(c.services, services) {
+		return
+	}
+	c.services = services
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// wait implements a Consul blocking query: it returns as soon as the catalog index differs
+// from index, or when the wait time elapses, the request goes away or the catalog stops
+func (c *ConsulCatalog) wait(ctx context.Context, index uint64, timeout time.Duration) (map[string][]ConsulServiceInstance, uint64) {
+	c.mu.Lock()
+	if index == 0 || index != c.index {
+		defer c.mu.Unlock()
+		return c.services, c.index
+	}
+	changed := c.changed
+	c.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-changed:
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-c.done:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.services, c.index
+}
+
+// Run refreshes the catalog from the discovery snapshot every scrape interval until ctx is cancelled
+func (c *ConsulCatalog) Run(ctx context.Context) {
+	defer close(c.done)
+
+	interval := c.m.refreshInterval()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		services, err := c.consulServices(ctx, c.m.Discover(ctx))
+		if err != nil {
+			logrus.Errorf("Failed to build Consul catalog: %v", err)
+		} else {
+			c.update(services)
+		}
+
+		if !c.m.waitTick(ctx, ticker, &interval) {
+			return
+		}
+	}
+}
+
+// blockingQuery parses the index and wait parameters of a request and waits accordingly
+func (c *ConsulCatalog) blockingQuery(w http.ResponseWriter, r *http.Request) (map[string][]ConsulServiceInstance, bool) {
+	query := r.URL.Query()
+
+	var index uint64
+	if value := query.Get("index"); value != "" {
+		var err error
+		if index, err = strconv.ParseUint(value, 10, 64); err != nil {
+			http.Error(w, "invalid index", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	timeout := consulDefaultWait
+	if value := query.Get("wait"); value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil {
+			http.Error(w, "invalid wait", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	timeout = min(timeout, consulMaxWait)
+
+	services, current := c.wait(r.Context(), index, timeout)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(current, 10))
+	w.Header().Set("X-Consul-KnownLeader", "true")
+	w.Header().Set("X-Consul-LastContact", "0")
+	return services, true
+}
+
+// filterInstances keeps the instances carrying every tag requested with ?tag=, and only
+// passing instances with ?passing
+func filterInstances(instances []ConsulServiceInstance, r *http.Request) []ConsulServiceInstance {
+	query := r.URL.Query()
+	tags := query["tag"]
+	passing := query.Has("passing") && query.Get("passing") != "false"
+
+	filtered := []ConsulServiceInstance{}
+	for _, instance := range instances {
+		if passing && instance.Status != "passing" {
+			continue
+		}
+		if !slices.ContainsFunc(tags, func(tag string) bool { return !slices.Contains(instance.Tags, tag) }) {
+			filtered = append(filtered, instance)
+		}
+	}
+	return filtered
+}
+
+// handleServices handles /v1/catalog/services, listing every service with the union of its tags
+func (c *ConsulCatalog) handleServices(w http.ResponseWriter, r *http.Request) {
+	services, ok := c.blockingQuery(w, r)
+	if !ok {
+		return
+	}
+
+	response := make(map[string][]string, len(services))
+	for name, instances := range services {
+		tags := []string{}
+		for _, instance := range instances {
+			for _, tag := range instance.Tags {
+				if !slices.Contains(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+		}
+		sort.Strings(tags)
+		response[name] = tags
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCatalogService handles /v1/catalog/service/{service}
+func (c *ConsulCatalog) handleCatalogService(w http.ResponseWriter, r *http.Request) {
+	services, ok := c.blockingQuery(w, r)
+	if !ok {
+		return
+	}
+
+	datacenter := c.m.cfg().ClusterName
+	response := []map[string]any{}
+	for _, instance := range filterInstances(services[mux.Vars(r)["service"]], r) {
+		response = append(response, map[string]any{
+			"ID":              "",
+			"Node":            instance.Node,
+			"Address":         instance.Address,
+			"Datacenter":      datacenter,
+			"TaggedAddresses": map[string]string{},
+			"NodeMeta":        map[string]string{"cluster": datacenter},
+			"ServiceID":       instance.ID,
+			"ServiceName":     mux.Vars(r)["service"],
+			"ServiceAddress":  instance.Address,
+			"ServiceTags":     instance.Tags,
+			"ServiceMeta":     instance.Meta,
+			"ServicePort":     instance.Port,
+		})
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleHealthService handles /v1/health/service/{service}, which recent Prometheus versions
+// query instead of the catalog endpoint
+func (c *ConsulCatalog) handleHealthService(w http.ResponseWriter, r *http.Request) {
+	services, ok := c.blockingQuery(w, r)
+	if !ok {
+		return
+	}
+
+	datacenter := c.m.cfg().ClusterName
+	name := mux.Vars(r)["service"]
+	response := []map[string]any{}
+	for _, instance := range filterInstances(services[name], r) {
+		response = append(response, map[string]any{
+			"Node": map[string]any{
+				"ID":              "",
+				"Node":            instance.Node,
+				"Address":         instance.Address,
+				"Datacenter":      datacenter,
+				"TaggedAddresses": map[string]string{},
+				"Meta":            map[string]string{"cluster": datacenter},
+			},
+			"Service": map[string]any{
+				"ID":      instance.ID,
+				"Service": name,
+				"Tags":    instance.Tags,
+				"Address": instance.Address,
+				"Meta":    instance.Meta,
+				"Port":    instance.Port,
+			},
+			"Checks": []map[string]string{
+				{
+					"Node":        instance.Node,
+					"CheckID":     "serfHealth",
+					"Name":        "MinIO node state",
+					"Status":      instance.Status,
+					"ServiceID":   instance.ID,
+					"ServiceName": name,
+				},
+			},
+		})
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAgentSelf handles /v1/agent/self, which Prometheus queries to learn the datacenter
+func (c *ConsulCatalog) handleAgentSelf(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"Config": map[string]string{
+			"Datacenter": c.m.cfg().ClusterName,
+			"NodeName":   "eos-mb-http-sd",
+		},
+	})
+}
+
+// Router returns the router serving the emulated Consul API
+func (c *ConsulCatalog) Router() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/catalog/services", c.handleServices).Methods("GET")
+	router.HandleFunc("/v1/catalog/service/{service}", c.handleCatalogService).Methods("GET")
+	router.HandleFunc("/v1/health/service/{service}", c.handleHealthService).Methods("GET")
+	router.HandleFunc("/v1/agent/self", c.handleAgentSelf).Methods("GET")
+	return router
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestConsulCatalogServices(t *testing.T) {
+	client := newTestMinIOClient(t)
+	catalog := NewConsulCatalog(client)
+	snapshot := &Snapshot{
+		Cluster: "test",
+		Nodes: []NodeInfo{
+			{Endpoint: "node1:9000", State: "online"},
+			{Endpoint: "node2:9000", State: "offline"},
+		},
+		Buckets: []minio.BucketInfo{{Name: "prod-payments", CreationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	services, err := catalog.consulServices(context.Background(), snapshot)
+	if err != nil {
+		t.Fatalf("Failed to build services: %v", err)
+	}
+	catalog.update(services)
+
+	server := httptest.NewServer(catalog.Router())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/health/service/minio-buckets")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if index := resp.Header.Get("X-Consul-Index"); index != "2" {
+		t.Errorf("Expected X-Consul-Index 2, got %q", index)
+	}
+
+	var entries []struct {
+		Service struct {
+			ID   string
+			Port int
+			Tags []string
+			Meta map[string]string
+		}
+		Checks []struct{ Status string }
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 instances, got %d", len(entries))
+	}
+	first := entries[0]
+	if first.Service.Port != 9000 || first.Service.Meta["sd_bucket"] != "prod-payments" || first.Service.Meta["metrics_path"] != "/minio/metrics/v3/bucket/api/prod-payments" {
+		t.Errorf("Unexpected service: %+v", first.Service)
+	}
+	if first.Checks[0].Status != "passing" || entries[1].Checks[0].Status != "critical" {
+		t.Errorf("Expected passing and critical checks, got %+v and %+v", first.Checks, entries[1].Checks)
+	}
+}
+
+func TestConsulCatalogBlockingQuery(t *testing.T) {
+	catalog := NewConsulCatalog(newTestMinIOClient(t))
+	catalog.update(map[string][]ConsulServiceInstance{"minio-server": {}})
+
+	// A query at the current index blocks until the catalog changes
+	result := make(chan uint64, 1)
+	go func() {
+		_, index := catalog.wait(context.Background(), 2, time.Minute)
+		result <- index
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("Blocking query returned before the catalog changed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	catalog.update(map[string][]ConsulServiceInstance{"minio-server": {{ID: "minio-server-node1:9000", Port: 9000}}})
+	select {
+	case index := <-result:
+		if index != 3 {
+			t.Errorf("Expected index 3, got %d", index)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Blocking query did not return after the catalog changed")
+	}
+
+	// An unchanged update does not bump the index, and the wait time is honoured
+	catalog.update(map[string][]ConsulServiceInstance{"minio-server": {{ID: "minio-server-node1:9000", Port: 9000}}})
+	if _, index := catalog.wait(context.Background(), 3, 10*time.Millisecond); index != 3 {
+		t.Errorf("Expected index 3 after timeout, got %d", index)
+	}
+}
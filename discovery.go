@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// Snapshot holds the result of the last discovery refresh for a cluster.
+// When a refresh fails, the previous nodes and buckets are kept so that
+// Prometheus keeps receiving the last known targets instead of an empty list.
+type Snapshot struct {
+	Cluster     string
+	Nodes       []NodeInfo
+	Buckets     []minio.BucketInfo
+	Metadata    map[string]BucketMetadata // Per-bucket details keyed by bucket name
+	UpdatedAt   time.Time                 // Last fully successful refresh; zero if none succeeded yet
+	RefreshedAt time.Time                 // Last refresh attempt
+	LastError   string
+}
+
+// Targets returns the node endpoints to use as scrape targets
+func (s *Snapshot) Targets() []string {
+	targets := make([]string, 0, len(s.Nodes))
+	for _, node := range s.Nodes {
+		targets = append(targets, node.Endpoint)
+	}
+	return targets
+}
+
+// currentSnapshot returns the last stored snapshot, or nil if discovery never ran
+func (m *MinIOClient) currentSnapshot() *Snapshot {
+	m.snapshotMu.RLock()
+	defer m.snapshotMu.RUnlock()
+	return m.snapshot
+}
+
+// invalidateSnapshot makes the next Discover refresh the snapshot; its data is served until then
+func (m *MinIOClient) invalidateSnapshot() {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+	if m.snapshot != nil {
+		stale := *m.snapshot
+		stale.RefreshedAt = time.Time{}
+		m.snapshot = &stale
+	}
+}
+
+// Discover returns the current snapshot, refreshing it first if it is older than the scrape interval
+func (m *MinIOClient) Discover(ctx context.Context) *Snapshot {
+	if snapshot := m.currentSnapshot(); snapshot != nil && time.Since(snapshot.RefreshedAt) < m.cfg().ScrapeInterval {
+		return snapshot
+	}
+
+	// Serialize refreshes so concurrent /sd requests share one round of MinIO calls
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	if snapshot := m.currentSnapshot(); snapshot != nil && time.Since(snapshot.RefreshedAt) < m.cfg().ScrapeInterval {
+		return snapshot
+	}
+
+	snapshot, _ := m.Refresh(ctx)
+	return snapshot
+}
+
+// Refresh queries MinIO for nodes and buckets and stores a new snapshot
+func (m *MinIOClient) Refresh(ctx context.Context) (*Snapshot, error) {
+	start := time.Now()
+	previous := m.currentSnapshot()
+	snapshot := &Snapshot{
+		Cluster:     m.cfg().ClusterName,
+		RefreshedAt: start,
+	}
+	if previous != nil {
+		snapshot.Nodes = previous.Nodes
+		snapshot.Buckets = previous.Buckets
+		snapshot.Metadata = previous.Metadata
+		snapshot.UpdatedAt = previous.UpdatedAt
+	}
+
+	nodes, nodesErr := m.GetClusterNodes(ctx)
+	if nodesErr == nil {
+		snapshot.Nodes = nodes
+	}
+
+	buckets, bucketsErr := m.ListBuckets(ctx)
+	if bucketsErr == nil {
+		snapshot.Buckets = buckets
+		snapshot.Metadata = m.enrichBuckets(ctx, buckets, snapshot.Metadata)
+	} else {
+		logrus.Warnf("Failed to list buckets (cluster may still be starting): %v", bucketsErr)
+	}
+
+	err := errors.Join(nodesErr, bucketsErr)
+	if err == nil {
+		snapshot.UpdatedAt = time.Now()
+	} else {
+		snapshot.LastError = err.Error()
+	}
+
+	m.snapshotMu.Lock()
+	m.snapshot = snapshot
+	m.snapshotMu.Unlock()
+
+	m.changes.Record(diffSnapshots(previous, snapshot)...)
+	m.metrics.ObserveRefresh(snapshot, time.Since(start), err)
+	return snapshot, err
+}
+
+// enrichBuckets fetches metadata for every bucket, with at most EnrichmentWorkers calls in
+// flight and within EnrichmentDeadline overall. Buckets whose metadata cannot be fetched in
+// time keep the metadata from the previous snapshot, if any. With bucket enrichment off,
+// no metadata is fetched.
+func (m *MinIOClient) enrichBuckets(ctx context.Context, buckets []minio.BucketInfo, previous map[string]BucketMetadata) map[string]BucketMetadata {
+	config := m.cfg()
+	if !config.BucketEnrichment {
+		return nil
+	}
+	if config.EnrichmentDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.EnrichmentDeadline)
+		defer cancel()
+	}
+
+	metadata := make(map[string]BucketMetadata, len(buckets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(config.EnrichmentWorkers, 1))
+	for _, bucket := range buckets {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(name string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			details, err := m.GetBucketMetadata(ctx, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logrus.Debugf("Keeping previous metadata for bucket %s: %v", name, err)
+				if old, ok := previous[name]; ok {
+					metadata[name] = old
+				}
+				return
+			}
+			metadata[name] = details
+		}(bucket.Name)
+	}
+	wg.Wait()
+	return metadata
+}
+
+// RunRefresher refreshes the snapshot every scrape interval until ctx is cancelled,
+// so that the first snapshot is loaded at startup and /sd requests rarely wait on MinIO
+func (m *MinIOClient) RunRefresher(ctx context.Context) {
+	interval := m.refreshInterval()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.refreshMu.Lock()
+		if _, err := m.Refresh(ctx); err != nil && ctx.Err() == nil {
+			logrus.Warnf("Background discovery refresh for cluster %s failed: %v", m.cfg().ClusterName, err)
+		}
+		m.refreshMu.Unlock()
+
+		if !m.waitTick(ctx, ticker, &interval) {
+			return
+		}
+	}
+}
+
+// refreshInterval returns the configured scrape interval, or 15s if it is not set
+func (m *MinIOClient) refreshInterval() time.Duration {
+	if interval := m.cfg().ScrapeInterval; interval > 0 {
+		return interval
+	}
+	return 15 * time.Second
+}
+
+// waitTick waits for the next tick of a periodic loop and follows scrape interval changes
+// made by a config reload. It returns false once ctx is cancelled.
+func (m *MinIOClient) waitTick(ctx context.Context, ticker *time.Ticker, interval *time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ticker.C:
+	}
+	if next := m.refreshInterval(); next != *interval {
+		*interval = next
+		ticker.Reset(next)
+	}
+	return true
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxUDPMessageSize is the largest DNS response sent over UDP before falling back to truncation
+const maxUDPMessageSize = 512
+
+// dnsZone holds the records served by the embedded DNS responder, keyed by lowercase FQDN
+type dnsZone struct {
+	serial uint32 // SOA serial, from the time the snapshot last changed
+	srv    map[string][]dnsmessage.SRVResource
+	a      map[string][]dnsmessage.AResource
+	aaaa   map[string][]dnsmessage.AAAAResource
+}
+
+// has reports whether the zone contains any record for name
+func (z dnsZone) has(name string) bool {
+	return len(z.srv[name]) > 0 || len(z.a[name]) > 0 || len(z.aaaa[name]) > 0
+}
+
+// DNSServer answers SRV, A and AAAA queries for the discovered nodes of every job from the
+// current snapshot. For a job, _<job>._tcp.<domain> has one SRV record per node and
+// <job>.<domain> the addresses of nodes discovered by IP. Nodes discovered by hostname are
+// SRV targets under their own name; nodes discovered by IP get a name in the zone.
+type DNSServer struct {
+	m      *MinIOClient
+	domain string // Fully qualified, lowercase, with trailing dot
+
+	mu       sync.Mutex
+	cached   dnsZone   // Zone built for cachedOf, served until the snapshot or config changes
+	cachedOf zoneInput // Snapshot and config cached was built from
+}
+
+// zoneInput identifies what a zone was built from. Refreshes and reloads replace the
+// snapshot and config rather than modifying them, so comparing pointers is enough.
+type zoneInput struct {
+	snapshot *Snapshot
+	config   *Config
+}
+
+// NewDNSServer creates a DNS responder for the given domain
+func NewDNSServer(m *MinIOClient, domain string) *DNSServer {
+	return &DNSServer{m: m, domain: strings.ToLower(strings.TrimSuffix(domain, ".")) + "."}
+}
+
+// ttl returns the record TTL, tied to the refresh interval
+func (d *DNSServer) ttl() uint32 {
+	return uint32(max(d.m.cfg().ScrapeInterval/time.Second, 1))
+}
+
+// zone builds the records for every job from the snapshot, using the same target groups as /sd
+func (d *DNSServer) zone(ctx context.Context, snapshot *Snapshot) (dnsZone, error) {
+	zone := dnsZone{
+		serial: uint32(max(snapshot.UpdatedAt.Unix(), 1)),
+		srv:    make(map[string][]dnsmessage.SRVResource),
+		a:      make(map[string][]dnsmessage.AResource),
+		aaaa:   make(map[string][]dnsmessage.AAAAResource),
+	}
+
+	configs, err := d.m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		return zone, err
+	}
+
+	for _, config := range configs {
+		job := strings.ToLower(config.JobName)
+		srvName := "_" + job + "._tcp." + d.domain
+		hostName := job + "." + d.domain
+
+		seen := make(map[string]bool)
+		for _, group := range d.m.buildTargetGroups(config, snapshot, d.m.bucketFilter()) {
+			for _, target := range group.Targets {
+				if seen[target] {
+					continue
+				}
+				seen[target] = true
+
+				host, portValue, err := net.SplitHostPort(target)
+				if err != nil {
+					continue
+				}
+				port, err := strconv.ParseUint(portValue, 10, 16)
+				if err != nil {
+					continue
+				}
+
+				targetName := strings.ToLower(strings.TrimSuffix(host, ".")) + "."
+				if ip := net.ParseIP(host); ip != nil {
+					targetName = "ip-" + strings.NewReplacer(".", "-", ":", "-").Replace(ip.String()) + ".node." + d.domain
+					for _, name := range []string{targetName, hostName} {
+						if ip4 := ip.To4(); ip4 != nil {
+							zone.a[name] = append(zone.a[name], dnsmessage.AResource{A: [4]byte(ip4)})
+						} else {
+							zone.aaaa[name] = append(zone.aaaa[name], dnsmessage.AAAAResource{AAAA: [16]byte(ip.To16())})
+						}
+					}
+				}
+
+				name, err := dnsmessage.NewName(targetName)
+				if err != nil {
+					logrus.Debugf("Skipping DNS record for target %s: %v", target, err)
+					continue
+				}
+				zone.srv[srvName] = append(zone.srv[srvName], dnsmessage.SRVResource{Port: uint16(port), Target: name})
+			}
+		}
+	}
+	return zone, nil
+}
+
+// cachedZone returns the zone for snapshot, building it only once per snapshot refresh or reload
+func (d *DNSServer) cachedZone(ctx context.Context, snapshot *Snapshot) (dnsZone, error) {
+	input := zoneInput{snapshot: snapshot, config: d.m.cfg()}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cachedOf == input {
+		return d.cached, nil
+	}
+	zone, err := d.zone(ctx, snapshot)
+	if err != nil {
+		return zone, err
+	}
+	d.cached, d.cachedOf = zone, input
+	return zone, nil
+}
+
+// answer builds the response to a DNS query. Queries outside the domain are refused,
+// unknown names in the domain get NXDOMAIN.
+func (d *DNSServer) answer(ctx context.Context, query []byte, limit int) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	question, err := parser.Question()
+
+	response := dnsmessage.Header{
+		ID:               header.ID,
+		Response:         true,
+		OpCode:           header.OpCode,
+		Authoritative:    true,
+		RecursionDesired: header.RecursionDesired,
+	}
+
+	var zone dnsZone
+	name := strings.ToLower(question.Name.String())
+	switch {
+	case err != nil:
+		response.RCode = dnsmessage.RCodeFormatError
+		return d.build(response, nil, zone)
+	case header.OpCode != 0:
+		response.RCode = dnsmessage.RCodeNotImplemented
+		return d.build(response, &question, zone)
+	case name != d.domain && !strings.HasSuffix(name, "."+d.domain):
+		response.RCode = dnsmessage.RCodeRefused
+		return d.build(response, &question, zone)
+	}
+
+	snapshot := d.m.currentSnapshot()
+	if snapshot == nil {
+		response.RCode = dnsmessage.RCodeServerFailure
+		return d.build(response, &question, zone)
+	}
+	if zone, err = d.cachedZone(ctx, snapshot); err != nil {
+		logrus.Errorf("Failed to build DNS zone: %v", err)
+		response.RCode = dnsmessage.RCodeServerFailure
+		return d.build(response, &question, dnsZone{})
+	}
+	if name != d.domain && !zone.has(name) {
+		response.RCode = dnsmessage.RCodeNameError
+	}
+
+	msg, err := d.build(response, &question, zone)
+	if err == nil && limit > 0 && len(msg) > limit {
+		// Make the client retry over TCP
+		response.Truncated = true
+		return d.build(response, &question, dnsZone{})
+	}
+	return msg, err
+}
+
+// build encodes a response with the records of zone answering question. NXDOMAIN and
+// NODATA responses carry the SOA of the domain so resolvers can cache them.
+func (d *DNSServer) build(header dnsmessage.Header, question *dnsmessage.Question, zone dnsZone) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if question == nil {
+		return builder.Finish()
+	}
+	if err := builder.Question(*question); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(question.Name.String())
+	ttl := d.ttl()
+	resourceHeader := func(name dnsmessage.Name) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: ttl}
+	}
+
+	var answers int
+	var additional []dnsmessage.Name
+	if name == d.domain && (question.Type == dnsmessage.TypeSOA || question.Type == dnsmessage.TypeALL) && zone.serial != 0 {
+		if err := d.soa(&builder, zone, resourceHeader); err != nil {
+			return nil, err
+		}
+		answers++
+	}
+	if question.Type == dnsmessage.TypeSRV || question.Type == dnsmessage.TypeALL {
+		for _, srv := range zone.srv[name] {
+			if err := builder.SRVResource(resourceHeader(question.Name), srv); err != nil {
+				return nil, err
+			}
+			answers++
+			if zone.has(strings.ToLower(srv.Target.String())) {
+				additional = append(additional, srv.Target)
+			}
+		}
+	}
+	n, err := d.addresses(&builder, question.Type, question.Name, zone, resourceHeader)
+	if err != nil {
+		return nil, err
+	}
+	answers += n
+
+	if err := builder.StartAuthorities(); err != nil {
+		return nil, err
+	}
+	negative := header.RCode == dnsmessage.RCodeNameError || (header.RCode == dnsmessage.RCodeSuccess && answers == 0)
+	if negative && !header.Truncated && zone.serial != 0 {
+		if err := d.soa(&builder, zone, resourceHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	// Addresses of SRV targets in the zone, saving clients a round trip
+	if err := builder.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	for _, target := range additional {
+		if _, err := d.addresses(&builder, dnsmessage.TypeALL, target, zone, resourceHeader); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Finish()
+}
+
+// addresses adds the A and/or AAAA records of name matching qtype and returns how many it added
+func (d *DNSServer) addresses(builder *dnsmessage.Builder, qtype dnsmessage.Type, name dnsmessage.Name, zone dnsZone, header func(dnsmessage.Name) dnsmessage.ResourceHeader) (int, error) {
+	key := strings.ToLower(name.String())
+	var n int
+	if qtype == dnsmessage.TypeA || qtype == dnsmessage.TypeALL {
+		for _, a := range zone.a[key] {
+			if err := builder.AResource(header(name), a); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	if qtype == dnsmessage.TypeAAAA || qtype == dnsmessage.TypeALL {
+		for _, aaaa := range zone.aaaa[key] {
+			if err := builder.AAAAResource(header(name), aaaa); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// soa adds the synthetic SOA record of the domain. Its minimum TTL, which resolvers use to
+// cache negative answers, is the record TTL.
+func (d *DNSServer) soa(builder *dnsmessage.Builder, zone dnsZone, header func(dnsmessage.Name) dnsmessage.ResourceHeader) error {
+	domain, err := dnsmessage.NewName(d.domain)
+	if err != nil {
+		return err
+	}
+	mbox, err := dnsmessage.NewName("hostmaster." + d.domain)
+	if err != nil {
+		return err
+	}
+	ttl := d.ttl()
+	return builder.SOAResource(header(domain), dnsmessage.SOAResource{
+		NS:      domain,
+		MBox:    mbox,
+		Serial:  zone.serial,
+		Refresh: ttl,
+		Retry:   ttl,
+		Expire:  10 * ttl,
+		MinTTL:  ttl,
+	})
+}
+
+// ServeUDP answers queries received on conn until it is closed
+func (d *DNSServer) ServeUDP(ctx context.Context, conn net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		response, err := d.answer(ctx, buf[:n], maxUDPMessageSize)
+		if err != nil {
+			logrus.Debugf("Dropping DNS query from %s: %v", addr, err)
+			continue
+		}
+		conn.WriteTo(response, addr)
+	}
+}
+
+// ServeTCP answers length-prefixed queries on connections accepted from listener until it is closed
+func (d *DNSServer) ServeTCP(ctx context.Context, listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				conn.SetDeadline(time.Now().Add(10 * time.Second))
+				var length uint16
+				if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+					return
+				}
+				query := make([]byte, length)
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				response, err := d.answer(ctx, query, 0)
+				if err != nil {
+					logrus.Debugf("Dropping DNS query from %s: %v", conn.RemoteAddr(), err)
+					return
+				}
+				if err := binary.Write(conn, binary.BigEndian, uint16(len(response))); err != nil {
+					return
+				}
+				if _, err := conn.Write(response); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// ListenAndServe serves DNS over UDP and TCP on addr until ctx is cancelled
+func (d *DNSServer) ListenAndServe(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for DNS on udp %s: %w", addr, err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to listen for DNS on tcp %s: %w", addr, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+		listener.Close()
+	}()
+
+	errs := make(chan error, 2)
+	go func() { errs <- d.ServeUDP(ctx, conn) }()
+	go func() { errs <- d.ServeTCP(ctx, listener) }()
+	return errors.Join(<-errs, <-errs)
+}
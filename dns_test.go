@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSServer(t *testing.T) {
+	client := newTestMinIOClient(t)
+	client.snapshot = &Snapshot{
+		Cluster: "test",
+		Nodes: []NodeInfo{
+			{Endpoint: "10.0.0.1:9000", State: "online"},
+			{Endpoint: "minio2.example.com:9000", State: "online"},
+		},
+	}
+	server := NewDNSServer(client, "sd.local")
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+	go server.ServeUDP(context.Background(), conn)
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, srvs, err := resolver.LookupSRV(ctx, "minio-server", "tcp", "sd.local")
+	if err != nil {
+		t.Fatalf("SRV lookup failed: %v", err)
+	}
+	var targets []string
+	for _, srv := range srvs {
+		if srv.Port != 9000 {
+			t.Errorf("Expected port 9000, got %d", srv.Port)
+		}
+		targets = append(targets, srv.Target)
+	}
+	slices.Sort(targets)
+	if !slices.Equal(targets, []string{"ip-10-0-0-1.node.sd.local.", "minio2.example.com."}) {
+		t.Errorf("Unexpected SRV targets: %v", targets)
+	}
+
+	addrs, err := resolver.LookupHost(ctx, "ip-10-0-0-1.node.sd.local")
+	if err != nil || !slices.Equal(addrs, []string{"10.0.0.1"}) {
+		t.Errorf("Expected 10.0.0.1, got %v (%v)", addrs, err)
+	}
+
+	// No bucket is included, so the minio-buckets job has no records
+	_, _, err = resolver.LookupSRV(ctx, "minio-buckets", "tcp", "sd.local")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+// queryDNS sends a query for name to server and returns the parsed response
+func queryDNS(t *testing.T, server *DNSServer, name string, qtype dnsmessage.Type) dnsmessage.Message {
+	t.Helper()
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 1},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack query: %v", err)
+	}
+	response, err := server.answer(context.Background(), packed, 0)
+	if err != nil {
+		t.Fatalf("Failed to answer %s: %v", name, err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(response); err != nil {
+		t.Fatalf("Failed to unpack response: %v", err)
+	}
+	return msg
+}
+
+func TestDNSServerNegativeAnswers(t *testing.T) {
+	client := newTestMinIOClient(t)
+	updated := time.Unix(1714564800, 0)
+	client.snapshot = &Snapshot{Cluster: "test", UpdatedAt: updated, Nodes: []NodeInfo{{Endpoint: "10.0.0.1:9000", State: "online"}}}
+	server := NewDNSServer(client, "sd.local")
+
+	for _, test := range []struct {
+		name  string
+		qtype dnsmessage.Type
+		rcode dnsmessage.RCode
+	}{
+		{"unknown.sd.local.", dnsmessage.TypeA, dnsmessage.RCodeNameError},
+		{"ip-10-0-0-1.node.sd.local.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess},
+	} {
+		msg := queryDNS(t, server, test.name, test.qtype)
+		if msg.RCode != test.rcode || len(msg.Answers) != 0 {
+			t.Errorf("%s: expected %v without answers, got %v with %d answers", test.name, test.rcode, msg.RCode, len(msg.Answers))
+		}
+		if len(msg.Authorities) != 1 {
+			t.Fatalf("%s: expected the SOA in the authority section, got %v", test.name, msg.Authorities)
+		}
+		soa, ok := msg.Authorities[0].Body.(*dnsmessage.SOAResource)
+		if !ok || msg.Authorities[0].Header.Name.String() != "sd.local." || soa.Serial != uint32(updated.Unix()) || soa.MinTTL != 60 {
+			t.Errorf("%s: unexpected SOA %v %+v", test.name, msg.Authorities[0].Header, msg.Authorities[0].Body)
+		}
+	}
+
+	if msg := queryDNS(t, server, "ip-10-0-0-1.node.sd.local.", dnsmessage.TypeA); len(msg.Answers) != 1 || len(msg.Authorities) != 0 {
+		t.Errorf("Expected one answer and no authority, got %v %v", msg.Answers, msg.Authorities)
+	}
+}
+
+func TestDNSServerCachesZone(t *testing.T) {
+	client := newTestMinIOClient(t)
+	snapshot := &Snapshot{Cluster: "test", Nodes: []NodeInfo{{Endpoint: "10.0.0.1:9000", State: "online"}}}
+	client.snapshot = snapshot
+	server := NewDNSServer(client, "sd.local")
+
+	if msg := queryDNS(t, server, "ip-10-0-0-1.node.sd.local.", dnsmessage.TypeA); len(msg.Answers) != 1 {
+		t.Fatalf("Expected one answer, got %v", msg.Answers)
+	}
+	cached := server.cachedOf
+	queryDNS(t, server, "minio-server.sd.local.", dnsmessage.TypeA)
+	if server.cachedOf != cached || cached.snapshot != snapshot {
+		t.Error("Expected the zone to be built once for the snapshot")
+	}
+
+	// A refresh replaces the snapshot, so the next query sees the new nodes
+	client.snapshot = &Snapshot{Cluster: "test", Nodes: []NodeInfo{{Endpoint: "10.0.0.2:9000", State: "online"}}}
+	if msg := queryDNS(t, server, "ip-10-0-0-2.node.sd.local.", dnsmessage.TypeA); len(msg.Answers) != 1 {
+		t.Errorf("Expected the zone to be rebuilt for the new snapshot, got %v", msg.Answers)
+	}
+	if msg := queryDNS(t, server, "ip-10-0-0-1.node.sd.local.", dnsmessage.TypeA); msg.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("Expected NXDOMAIN for the removed node, got %v", msg.RCode)
+	}
+}
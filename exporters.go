@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// VMAgentConfig is the part of a vmagent -promscrape.config file rendered by this service
+type VMAgentConfig struct {
+	ScrapeConfigs []VMAgentScrapeConfig `yaml:"scrape_configs"`
+}
+
+// VMAgentScrapeConfig is a Prometheus scrape_config extended with VictoriaMetrics-specific options
+type VMAgentScrapeConfig struct {
+	PrometheusScrapeConfig `yaml:",inline"`
+
+	// stream_parse processes the scraped response in chunks, keeping vmagent memory flat
+	// on the large responses of MinIO bucket metrics
+	StreamParse bool `yaml:"stream_parse,omitempty"`
+	// series_limit caps the number of unique series a target may expose
+	SeriesLimit int `yaml:"series_limit,omitempty"`
+}
+
+// GenerateVMAgentConfig renders the discovered jobs as vmagent scrape configs. vmagent
+// understands http_sd_configs, so the jobs discover their targets from /sd like Prometheus.
+func (m *MinIOClient) GenerateVMAgentConfig(ctx context.Context) (VMAgentConfig, error) {
+	prometheusConfig, err := m.GeneratePrometheusConfig(ctx)
+	if err != nil {
+		return VMAgentConfig{}, err
+	}
+
+	vmagentConfig := VMAgentConfig{ScrapeConfigs: []VMAgentScrapeConfig{}}
+	for _, config := range prometheusConfig.ScrapeConfigs {
+		vmagentConfig.ScrapeConfigs = append(vmagentConfig.ScrapeConfigs, VMAgentScrapeConfig{
+			PrometheusScrapeConfig: config,
+			StreamParse:            true,
+			SeriesLimit:            m.cfg().VMAgentSeriesLimit,
+		})
+	}
+	return vmagentConfig, nil
+}
+
+// RenderVMAgentConfig renders the generated vmagent configuration as YAML
+func (m *MinIOClient) RenderVMAgentConfig(ctx context.Context) ([]byte, error) {
+	vmagentConfig, err := m.GenerateVMAgentConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate vmagent config: %w", err)
+	}
+	data, err := yaml.Marshal(vmagentConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vmagent config: %w", err)
+	}
+	return data, nil
+}
+
+// tomlString quotes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// RenderTelegrafConfig renders every target group of the snapshot as a Telegraf
+// [[inputs.prometheus]] block. Telegraf has no HTTP service discovery, so the URLs are
+// built from the same target groups as /sd and the output has to be regenerated when
+// nodes or buckets change.
+func (m *MinIOClient) RenderTelegrafConfig(ctx context.Context, snapshot *Snapshot) ([]byte, error) {
+	configs, err := m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate scrape configs: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by eos_mb_http_sd for cluster %s\n", m.cfg().ClusterName)
+	for _, config := range configs {
+		for _, group := range m.buildTargetGroups(config, snapshot, m.bucketFilter()) {
+			if len(group.Targets) == 0 {
+				continue
+			}
+
+			scheme := group.Labels["__scheme__"]
+			if scheme == "" {
+				scheme = config.Scheme
+			}
+			metricsPath := group.Labels["__metrics_path__"]
+			if metricsPath == "" {
+				metricsPath = config.MetricsPath
+			}
+			urls := make([]string, 0, len(group.Targets))
+			for _, target := range group.Targets {
+				urls = append(urls, tomlString(scheme+"://"+target+metricsPath))
+			}
+
+			tags := map[string]string{"job": config.JobName}
+			for name, value := range group.Labels {
+				if !strings.HasPrefix(name, "__") {
+					tags[name] = value
+				}
+			}
+
+			b.WriteString("\n[[inputs.prometheus]]\n")
+			fmt.Fprintf(&b, "  urls = [%s]\n", strings.Join(urls, ", "))
+			b.WriteString("  metric_version = 2\n")
+			if config.ScrapeInterval != "" {
+				fmt.Fprintf(&b, "  interval = %s\n", tomlString(config.ScrapeInterval))
+			}
+			if config.ScrapeTimeout != "" {
+				fmt.Fprintf(&b, "  timeout = %s\n", tomlString(config.ScrapeTimeout))
+			}
+			if m.cfg().TelegrafMinIOTokenFile != "" {
+				fmt.Fprintf(&b, "  bearer_token = %s\n", tomlString(m.cfg().TelegrafMinIOTokenFile))
+			}
+			b.WriteString("  [inputs.prometheus.tags]\n")
+			for _, name := range slices.Sorted(maps.Keys(tags)) {
+				fmt.Fprintf(&b, "    %s = %s\n", name, tomlString(tags[name]))
+			}
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// handleVMAgentConfig handles the /vmagent/scrape_configs.yaml endpoint
+func (m *MinIOClient) handleVMAgentConfig(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("vmagent config request from %s", r.RemoteAddr)
+
+	data, err := m.RenderVMAgentConfig(r.Context())
+	if err != nil {
+		logrus.Errorf("Failed to render vmagent config: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
+// handleTelegrafConfig handles the /telegraf/inputs.conf endpoint
+func (m *MinIOClient) handleTelegrafConfig(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("Telegraf config request from %s", r.RemoteAddr)
+
+	data, err := m.RenderTelegrafConfig(r.Context(), m.Discover(r.Context()))
+	if err != nil {
+		logrus.Errorf("Failed to render Telegraf config: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/toml")
+	w.Write(data)
+}
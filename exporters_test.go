@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderVMAgentConfig(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ExternalURL = "http://sd.example.com:8080"
+		c.VMAgentSeriesLimit = 10000
+	})
+
+	data, err := client.RenderVMAgentConfig(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to render vmagent config: %v", err)
+	}
+
+	var config struct {
+		ScrapeConfigs []map[string]any `yaml:"scrape_configs"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to decode vmagent config: %v", err)
+	}
+	if len(config.ScrapeConfigs) != 2 {
+		t.Fatalf("Expected 2 scrape configs, got %d", len(config.ScrapeConfigs))
+	}
+	for _, job := range config.ScrapeConfigs {
+		if job["stream_parse"] != true || job["series_limit"] != 10000 {
+			t.Errorf("Job %v: missing VictoriaMetrics options: %v", job["job_name"], job)
+		}
+		if _, ok := job["http_sd_configs"]; !ok {
+			t.Errorf("Job %v: missing http_sd_configs", job["job_name"])
+		}
+	}
+}
+
+func TestRenderTelegrafConfig(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.TelegrafMinIOTokenFile = "/etc/telegraf/minio.token"
+		c.ScrapeInterval = 15 * time.Second
+	})
+	snapshot := &Snapshot{
+		Cluster: "test",
+		Nodes:   []NodeInfo{{Endpoint: "node1:9000"}, {Endpoint: "node2:9000"}},
+		Buckets: []minio.BucketInfo{{Name: "prod-payments", CreationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	data, err := client.RenderTelegrafConfig(context.Background(), snapshot)
+	if err != nil {
+		t.Fatalf("Failed to render Telegraf config: %v", err)
+	}
+	output := string(data)
+
+	if count := strings.Count(output, "[[inputs.prometheus]]"); count != 2 {
+		t.Errorf("Expected 2 inputs.prometheus blocks, got %d:\n%s", count, output)
+	}
+	for _, expected := range []string{
+		`urls = ["http://node1:9000/minio/metrics/v3", "http://node2:9000/minio/metrics/v3"]`,
+		`urls = ["http://node1:9000/minio/metrics/v3/bucket/api/prod-payments", "http://node2:9000/minio/metrics/v3/bucket/api/prod-payments"]`,
+		`bearer_token = "/etc/telegraf/minio.token"`,
+		`sd_bucket = "prod-payments"`,
+		`job = "minio-buckets"`,
+		`interval = "15s"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %s, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestTOMLString(t *testing.T) {
+	if got := tomlString("a\"b\\c\n"); got != `"a\"b\\c\u000a"` {
+		t.Errorf("Unexpected TOML string: %s", got)
+	}
+}
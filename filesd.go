@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// file_sd output formats
+const (
+	FileSDFormatJSON = "json"
+	FileSDFormatYAML = "yaml"
+)
+
+// fileSDTargetGroup is a target group in the Prometheus file_sd format
+type fileSDTargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// encodeFileSD renders target groups in the given file_sd format
+func encodeFileSD(groups []ServiceDiscoveryResponse, format string) ([]byte, error) {
+	fileGroups := make([]fileSDTargetGroup, 0, len(groups))
+	for _, group := range groups {
+		targets := group.Targets
+		if targets == nil {
+			targets = []string{}
+		}
+		fileGroups = append(fileGroups, fileSDTargetGroup{Targets: targets, Labels: group.Labels})
+	}
+
+	switch format {
+	case FileSDFormatJSON:
+		data, err := json.MarshalIndent(fileGroups, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case FileSDFormatYAML:
+		return yaml.Marshal(fileGroups)
+	default:
+		return nil, fmt.Errorf("unknown file_sd format %q (expected %s or %s)", format, FileSDFormatJSON, FileSDFormatYAML)
+	}
+}
+
+// writeFileIfChanged atomically replaces path with data unless it already has that content.
+// The data is written to a temporary file in the same directory and renamed over path, so
+// Prometheus never reads a partially written file. It reports whether the file was written.
+func writeFileIfChanged(path string, data []byte) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteFileSD writes the target groups of every job from the given snapshot to
+// <dir>/<job>.<format>, using the same target groups as the /sd endpoint
+func (m *MinIOClient) WriteFileSD(ctx context.Context, snapshot *Snapshot) error {
+	configs, err := m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate scrape configs: %w", err)
+	}
+	if err := os.MkdirAll(m.cfg().FileSDDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create file_sd directory: %w", err)
+	}
+
+	var errs []error
+	for _, config := range configs {
+		data, err := encodeFileSD(m.buildTargetGroups(config, snapshot, m.bucketFilter()), m.cfg().FileSDFormat)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(m.cfg().FileSDDir, config.JobName+"."+m.cfg().FileSDFormat)
+		written, err := writeFileIfChanged(path, data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s: %w", path, err))
+			continue
+		}
+		if written {
+			logrus.Infof("Updated file_sd file %s", path)
+		} else {
+			logrus.Debugf("file_sd file %s is unchanged", path)
+		}
+
+		// After a file_sd_format change, Prometheus would otherwise keep reading the job's
+		// file in the previous format next to the new one
+		for _, format := range []string{FileSDFormatJSON, FileSDFormatYAML} {
+			if format == m.cfg().FileSDFormat {
+				continue
+			}
+			stale := filepath.Join(m.cfg().FileSDDir, config.JobName+"."+format)
+			if err := os.Remove(stale); err == nil {
+				logrus.Infof("Removed file_sd file %s left in the previous format", stale)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("failed to remove %s: %w", stale, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunFileSDWriter rewrites the file_sd files every scrape interval until ctx is cancelled.
+// Nothing is written until discovery has succeeded once, so a restart while MinIO is
+// unreachable does not replace the last known targets with empty files.
+func (m *MinIOClient) RunFileSDWriter(ctx context.Context) {
+	interval := m.refreshInterval()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		snapshot := m.Discover(ctx)
+		if snapshot.UpdatedAt.IsZero() {
+			logrus.Warnf("Skipping file_sd update for cluster %s: no successful discovery yet", m.cfg().ClusterName)
+		} else if err := m.WriteFileSD(ctx, snapshot); err != nil {
+			logrus.Errorf("Failed to write file_sd files: %v", err)
+		}
+
+		if !m.waitTick(ctx, ticker, &interval) {
+			return
+		}
+	}
+}
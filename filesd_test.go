@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteFileIfChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "minio-server.json")
+
+	for i, tt := range []struct {
+		data    string
+		written bool
+	}{
+		{`[{"targets":["node1:9000"]}]`, true},
+		{`[{"targets":["node1:9000"]}]`, false},
+		{`[{"targets":["node1:9000","node2:9000"]}]`, true},
+	} {
+		written, err := writeFileIfChanged(path, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("Write %d failed: %v", i, err)
+		}
+		if written != tt.written {
+			t.Errorf("Write %d: expected written=%t, got %t", i, tt.written, written)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestWriteFileSD(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := &Snapshot{
+		Cluster:   "test",
+		UpdatedAt: time.Now(),
+		Nodes:     []NodeInfo{{Endpoint: "node1:9000", State: "online"}},
+		Buckets: []minio.BucketInfo{
+			{Name: "prod-payments", CreationDate: created},
+			{Name: "prod-tmp", CreationDate: created},
+		},
+	}
+
+	for _, format := range []string{FileSDFormatJSON, FileSDFormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			client := newTestMinIOClient(t, func(c *Config) {
+				c.BucketExcludePattern = "*tmp*"
+				c.FileSDDir = t.TempDir()
+				c.FileSDFormat = format
+			})
+
+			if err := client.WriteFileSD(context.Background(), snapshot); err != nil {
+				t.Fatalf("WriteFileSD failed: %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(client.cfg().FileSDDir, "minio-buckets."+format))
+			if err != nil {
+				t.Fatalf("Failed to read file_sd file: %v", err)
+			}
+			var groups []fileSDTargetGroup
+			if format == FileSDFormatJSON {
+				err = json.Unmarshal(data, &groups)
+			} else {
+				err = yaml.Unmarshal(data, &groups)
+			}
+			if err != nil {
+				t.Fatalf("Failed to decode file_sd file: %v", err)
+			}
+			if len(groups) != 1 || groups[0].Labels["sd_bucket"] != "prod-payments" || len(groups[0].Targets) != 1 {
+				t.Errorf("Unexpected target groups: %+v", groups)
+			}
+
+			if _, err := os.Stat(filepath.Join(client.cfg().FileSDDir, "minio-server."+format)); err != nil {
+				t.Errorf("Expected minio-server file: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteFileSDRemovesPreviousFormat(t *testing.T) {
+	snapshot := &Snapshot{Cluster: "test", UpdatedAt: time.Now(), Nodes: []NodeInfo{{Endpoint: "node1:9000", State: "online"}}}
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other.json")
+	if err := os.WriteFile(other, []byte("[]\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write unrelated file: %v", err)
+	}
+
+	config := testConfig(func(c *Config) { c.FileSDDir = dir })
+	client, err := NewMinIOClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create MinIO client: %v", err)
+	}
+	if err := client.WriteFileSD(context.Background(), snapshot); err != nil {
+		t.Fatalf("WriteFileSD failed: %v", err)
+	}
+
+	config.FileSDFormat = FileSDFormatYAML
+	if _, err := client.ApplyConfig(config); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if err := client.WriteFileSD(context.Background(), snapshot); err != nil {
+		t.Fatalf("WriteFileSD failed: %v", err)
+	}
+
+	for _, job := range scrapeJobs {
+		if _, err := os.Stat(filepath.Join(dir, job+".yaml")); err != nil {
+			t.Errorf("Expected %s.yaml: %v", job, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, job+".json")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected %s.json to be removed, got %v", job, err)
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected files of other jobs to be kept: %v", err)
+	}
+}
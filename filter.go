@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Bucket filter rules reported in inclusion decisions
+const (
+	RuleBucketPattern        = "bucket_pattern"
+	RuleBucketExcludePattern = "bucket_exclude_pattern"
+)
+
+// BucketFilter holds the rules deciding which buckets are emitted as targets
+type BucketFilter struct {
+	Pattern        string
+	ExcludePattern string
+}
+
+// BucketDecision records whether a bucket is emitted and which rule decided it
+type BucketDecision struct {
+	Included bool   `json:"included"`
+	Rule     string `json:"rule"`
+	Reason   string `json:"reason"`
+}
+
+// bucketFilter returns the filter built from the configured patterns
+func (m *MinIOClient) bucketFilter() BucketFilter {
+	return m.cfg().filter()
+}
+
+// filter returns the bucket filter built from the patterns of a configuration
+func (c Config) filter() BucketFilter {
+	return BucketFilter{
+		Pattern:        c.BucketPattern,
+		ExcludePattern: c.BucketExcludePattern,
+	}
+}
+
+// Decide applies the include pattern, then the exclude pattern, to a bucket name
+func (f BucketFilter) Decide(name string) BucketDecision {
+	if !matchPattern(name, f.Pattern) {
+		return BucketDecision{
+			Included: false,
+			Rule:     RuleBucketPattern,
+			Reason:   fmt.Sprintf("name does not match bucket_pattern %q", f.Pattern),
+		}
+	}
+
+	if f.ExcludePattern != "" && matchPattern(name, f.ExcludePattern) {
+		return BucketDecision{
+			Included: false,
+			Rule:     RuleBucketExcludePattern,
+			Reason:   fmt.Sprintf("name matches bucket_exclude_pattern %q", f.ExcludePattern),
+		}
+	}
+
+	pattern := f.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	return BucketDecision{
+		Included: true,
+		Rule:     RuleBucketPattern,
+		Reason:   fmt.Sprintf("name matches bucket_pattern %q", pattern),
+	}
+}
+
+// Apply returns the buckets included by the filter, in their original order
+func (f BucketFilter) Apply(buckets []minio.BucketInfo) []minio.BucketInfo {
+	var filtered []minio.BucketInfo
+	for _, bucket := range buckets {
+		if f.Decide(bucket.Name).Included {
+			filtered = append(filtered, bucket)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minio/madmin-go/v4"
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// Health statuses, from best to worst
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// Component check results
+const (
+	CheckStatusOK      = "ok"
+	CheckStatusFail    = "fail"
+	CheckStatusUnknown = "unknown"
+)
+
+// credentialErrorCodes are MinIO error codes meaning the access or secret key is wrong
+var credentialErrorCodes = map[string]bool{
+	"InvalidAccessKeyId":    true,
+	"SignatureDoesNotMatch": true,
+	"XMinioInvalidToken":    true,
+	"ExpiredToken":          true,
+}
+
+// healthProbeInterval is how long the result of the live MinIO checks is reused, so that
+// frequent /health probes do not multiply calls against the cluster
+const healthProbeInterval = 5 * time.Second
+
+// healthProbe holds the result of the live MinIO checks behind /health
+type healthProbe struct {
+	at         time.Time
+	bucketsErr error
+	nodes      []NodeInfo
+	nodesErr   error
+}
+
+// healthProber runs the live checks at most once per healthProbeInterval; concurrent
+// requests wait for the running probe and share its result
+type healthProber struct {
+	mu   sync.Mutex
+	last *healthProbe
+}
+
+// HealthCheck is the result of checking a single component
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NodeHealth is the state of a single MinIO node as reported by the admin API
+type NodeHealth struct {
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+}
+
+// ClusterHealth is the health report of a single MinIO cluster
+type ClusterHealth struct {
+	Status             string                 `json:"status"`
+	Checks             map[string]HealthCheck `json:"checks,omitempty"`
+	Nodes              []NodeHealth           `json:"nodes,omitempty"`
+	NodesOnline        int                    `json:"nodes_online"`
+	NodesTotal         int                    `json:"nodes_total"`
+	SnapshotAgeSeconds *float64               `json:"snapshot_age_seconds"`
+	LastError          string                 `json:"last_error,omitempty"`
+	CircuitBreaker     BreakerStatus          `json:"circuit_breaker"`
+	Reasons            []string               `json:"reasons,omitempty"`
+}
+
+// HealthReport is the response of the /health endpoint
+type HealthReport struct {
+	Status    string                   `json:"status"`
+	Timestamp string                   `json:"timestamp"`
+	Clusters  map[string]ClusterHealth `json:"clusters"`
+}
+
+// minioErrorCode extracts the S3 or admin API error code from a (possibly wrapped) error
+func minioErrorCode(err error) string {
+	var s3Err minio.ErrorResponse
+	if errors.As(err, &s3Err) {
+		return s3Err.Code
+	}
+	var adminErr madmin.ErrorResponse
+	if errors.As(err, &adminErr) {
+		return adminErr.Code
+	}
+	return ""
+}
+
+// healthInput holds everything needed to evaluate the health of a cluster
+type healthInput struct {
+	bucketsErr error
+	nodes      []NodeInfo
+	nodesErr   error
+	snapshot   *Snapshot
+	breaker    BreakerStatus
+	maxAge     time.Duration
+	now        time.Time
+}
+
+// evaluateClusterHealth turns the results of the live checks into a cluster health report.
+// A cluster is unhealthy when buckets cannot be listed (S3 API unreachable or credentials
+// rejected) and degraded when discovery still works but something is impaired: no admin
+// permission, offline nodes, an open circuit breaker or a stale snapshot.
+func evaluateClusterHealth(in healthInput) ClusterHealth {
+	health := ClusterHealth{
+		Status:         HealthStatusHealthy,
+		Checks:         make(map[string]HealthCheck),
+		CircuitBreaker: in.breaker,
+	}
+	degrade := func(status, reason string) {
+		if status == HealthStatusUnhealthy || health.Status == HealthStatusHealthy {
+			health.Status = status
+		}
+		health.Reasons = append(health.Reasons, reason)
+	}
+
+	// S3 API reachability and credential validity
+	switch {
+	case in.bucketsErr == nil:
+		health.Checks["s3_api"] = HealthCheck{Status: CheckStatusOK}
+		health.Checks["credentials"] = HealthCheck{Status: CheckStatusOK}
+	case errors.Is(in.bucketsErr, ErrCircuitOpen):
+		// The open breaker degrades the cluster below
+		health.Checks["s3_api"] = HealthCheck{Status: CheckStatusUnknown, Error: in.bucketsErr.Error()}
+		health.Checks["credentials"] = HealthCheck{Status: CheckStatusUnknown}
+	case credentialErrorCodes[minioErrorCode(in.bucketsErr)]:
+		health.Checks["s3_api"] = HealthCheck{Status: CheckStatusOK}
+		health.Checks["credentials"] = HealthCheck{Status: CheckStatusFail, Error: in.bucketsErr.Error()}
+		degrade(HealthStatusUnhealthy, "credentials rejected by MinIO")
+	case minioErrorCode(in.bucketsErr) == "AccessDenied":
+		// MinIO answered, but the key may not list buckets
+		health.Checks["s3_api"] = HealthCheck{Status: CheckStatusOK}
+		health.Checks["credentials"] = HealthCheck{Status: CheckStatusFail, Error: in.bucketsErr.Error()}
+		degrade(HealthStatusUnhealthy, "credentials lack permission to list buckets")
+	default:
+		health.Checks["s3_api"] = HealthCheck{Status: CheckStatusFail, Error: in.bucketsErr.Error()}
+		health.Checks["credentials"] = HealthCheck{Status: CheckStatusUnknown}
+		degrade(HealthStatusUnhealthy, "S3 API unreachable")
+	}
+
+	// Admin API permission
+	switch {
+	case in.nodesErr == nil:
+		health.Checks["admin_api"] = HealthCheck{Status: CheckStatusOK}
+	case errors.Is(in.nodesErr, ErrCircuitOpen):
+		health.Checks["admin_api"] = HealthCheck{Status: CheckStatusUnknown, Error: in.nodesErr.Error()}
+	default:
+		health.Checks["admin_api"] = HealthCheck{Status: CheckStatusFail, Error: in.nodesErr.Error()}
+		if code := minioErrorCode(in.nodesErr); code == "AccessDenied" {
+			degrade(HealthStatusDegraded, "admin API permission denied, node discovery unavailable")
+		} else {
+			degrade(HealthStatusDegraded, "admin API call failed, node discovery unavailable")
+		}
+	}
+
+	// Per-node state
+	for _, node := range in.nodes {
+		health.Nodes = append(health.Nodes, NodeHealth{Endpoint: node.Endpoint, State: node.State})
+		if node.State == "online" {
+			health.NodesOnline++
+		}
+	}
+	health.NodesTotal = len(in.nodes)
+	if offline := health.NodesTotal - health.NodesOnline; offline > 0 {
+		degrade(HealthStatusDegraded, strconv.Itoa(offline)+" node(s) not online")
+	}
+
+	if in.breaker.State != BreakerClosed.String() {
+		degrade(HealthStatusDegraded, "circuit breaker "+in.breaker.State)
+	}
+
+	// Snapshot freshness
+	if in.snapshot != nil {
+		health.LastError = in.snapshot.LastError
+		if !in.snapshot.UpdatedAt.IsZero() {
+			age := in.now.Sub(in.snapshot.UpdatedAt).Seconds()
+			health.SnapshotAgeSeconds = &age
+			if in.maxAge > 0 && in.now.Sub(in.snapshot.UpdatedAt) > in.maxAge {
+				degrade(HealthStatusDegraded, "discovery snapshot is stale")
+			}
+		}
+	}
+	if health.SnapshotAgeSeconds == nil {
+		degrade(HealthStatusDegraded, "no discovery snapshot loaded yet")
+	}
+
+	return health
+}
+
+// worseStatus returns the worse of two health statuses
+func worseStatus(a, b string) string {
+	rank := map[string]int{HealthStatusHealthy: 0, HealthStatusDegraded: 1, HealthStatusUnhealthy: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// probe returns the result of the live checks against MinIO, running them unless the last
+// result is younger than healthProbeInterval. The checks are not tied to the request that
+// triggers them, since their result is shared.
+func (p *healthProber) probe(ctx context.Context, m *MinIOClient) *healthProbe {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last != nil && time.Since(p.last.at) < healthProbeInterval {
+		return p.last
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	probe := &healthProbe{}
+	_, probe.bucketsErr = m.ListBuckets(ctx)
+	probe.nodes, probe.nodesErr = m.GetClusterNodes(ctx)
+	probe.at = time.Now()
+	p.last = probe
+	return probe
+}
+
+// CheckHealth runs live checks against MinIO, at most once per healthProbeInterval, and
+// builds the health report
+func (m *MinIOClient) CheckHealth(ctx context.Context) HealthReport {
+	probe := m.health.probe(ctx, m)
+
+	health := evaluateClusterHealth(healthInput{
+		bucketsErr: probe.bucketsErr,
+		nodes:      probe.nodes,
+		nodesErr:   probe.nodesErr,
+		snapshot:   m.currentSnapshot(),
+		breaker:    m.breaker.Status(),
+		maxAge:     m.cfg().ReadinessMaxAge,
+		now:        time.Now(),
+	})
+
+	return HealthReport{
+		Status:    worseStatus(HealthStatusHealthy, health.Status),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Clusters:  map[string]ClusterHealth{m.cfg().ClusterName: health},
+	}
+}
+
+// handleHealth handles the /health endpoint. It returns 200 for healthy and degraded
+// clusters and 503 when a cluster is unhealthy. Node details and check errors are only
+// included with ?verbose, as are the last errors and the reasons for the status, and only
+// for callers that authenticate when auth is enabled, since the endpoint itself is open.
+func (m *MinIOClient) handleHealth(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("Health check request from %s", r.RemoteAddr)
+
+	report := m.CheckHealth(r.Context())
+
+	verbose := false
+	if r.URL.Query().Has("verbose") {
+		value := r.URL.Query().Get("verbose")
+		verbose = value == "" || value == "1" || value == "true"
+	}
+	if verbose && !m.authenticated(r) {
+		logrus.Debugf("Omitting health details for unauthenticated request from %s", r.RemoteAddr)
+		verbose = false
+	}
+
+	status := http.StatusOK
+	if report.Status == HealthStatusUnhealthy {
+		logrus.Warnf("Health check failed: %+v", report.Clusters)
+		status = http.StatusServiceUnavailable
+	} else {
+		logrus.Debugf("Health check passed with status %s", report.Status)
+	}
+
+	// Error texts and reasons may reveal endpoints and credential failures
+	if !verbose {
+		for name, cluster := range report.Clusters {
+			cluster.Nodes = nil
+			cluster.LastError = ""
+			cluster.Reasons = nil
+			cluster.CircuitBreaker.LastError = ""
+			for check, result := range cluster.Checks {
+				cluster.Checks[check] = HealthCheck{Status: result.Status}
+			}
+			report.Clusters[name] = cluster
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/madmin-go/v4"
+	"github.com/minio/minio-go/v7"
+)
+
+func TestEvaluateClusterHealth(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fresh := &Snapshot{UpdatedAt: now.Add(-time.Minute)}
+	closed := BreakerStatus{State: BreakerClosed.String()}
+	online := []NodeInfo{{Endpoint: "node1:9000", State: "online"}, {Endpoint: "node2:9000", State: "online"}}
+
+	tests := []struct {
+		name     string
+		input    healthInput
+		expected string
+		checks   map[string]string
+	}{
+		{
+			name:     "all good",
+			input:    healthInput{nodes: online, snapshot: fresh, breaker: closed},
+			expected: HealthStatusHealthy,
+			checks:   map[string]string{"s3_api": CheckStatusOK, "credentials": CheckStatusOK, "admin_api": CheckStatusOK},
+		},
+		{
+			name: "invalid credentials",
+			input: healthInput{
+				bucketsErr: fmt.Errorf("failed to list buckets: %w", minio.ErrorResponse{Code: "InvalidAccessKeyId"}),
+				nodes:      online, snapshot: fresh, breaker: closed,
+			},
+			expected: HealthStatusUnhealthy,
+			checks:   map[string]string{"s3_api": CheckStatusOK, "credentials": CheckStatusFail},
+		},
+		{
+			name: "list buckets denied",
+			input: healthInput{
+				bucketsErr: fmt.Errorf("ListBuckets: %w", minio.ErrorResponse{Code: "AccessDenied"}),
+				nodes:      online, snapshot: fresh, breaker: closed,
+			},
+			expected: HealthStatusUnhealthy,
+			checks:   map[string]string{"s3_api": CheckStatusOK, "credentials": CheckStatusFail},
+		},
+		{
+			name:     "S3 API unreachable",
+			input:    healthInput{bucketsErr: errors.New("connection refused"), nodes: online, snapshot: fresh, breaker: closed},
+			expected: HealthStatusUnhealthy,
+			checks:   map[string]string{"s3_api": CheckStatusFail, "credentials": CheckStatusUnknown},
+		},
+		{
+			name:     "admin permission denied",
+			input:    healthInput{nodesErr: madmin.ErrorResponse{Code: "AccessDenied"}, snapshot: fresh, breaker: closed},
+			expected: HealthStatusDegraded,
+			checks:   map[string]string{"s3_api": CheckStatusOK, "admin_api": CheckStatusFail},
+		},
+		{
+			name: "node offline",
+			input: healthInput{
+				nodes:    []NodeInfo{{Endpoint: "node1:9000", State: "online"}, {Endpoint: "node2:9000", State: "offline"}},
+				snapshot: fresh, breaker: closed,
+			},
+			expected: HealthStatusDegraded,
+		},
+		{
+			name:     "stale snapshot",
+			input:    healthInput{nodes: online, snapshot: &Snapshot{UpdatedAt: now.Add(-time.Hour)}, breaker: closed, maxAge: 5 * time.Minute},
+			expected: HealthStatusDegraded,
+		},
+		{
+			name:     "no snapshot yet",
+			input:    healthInput{nodes: online, breaker: closed},
+			expected: HealthStatusDegraded,
+		},
+		{
+			name: "circuit breaker open on list buckets",
+			input: healthInput{
+				bucketsErr: fmt.Errorf("ListBuckets: %w", ErrCircuitOpen), nodesErr: ErrCircuitOpen,
+				nodes: online, snapshot: fresh, breaker: BreakerStatus{State: BreakerOpen.String()},
+			},
+			expected: HealthStatusDegraded,
+			checks:   map[string]string{"s3_api": CheckStatusUnknown, "credentials": CheckStatusUnknown, "admin_api": CheckStatusUnknown},
+		},
+		{
+			name:     "circuit breaker open",
+			input:    healthInput{nodes: online, snapshot: fresh, breaker: BreakerStatus{State: BreakerOpen.String()}},
+			expected: HealthStatusDegraded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.now = now
+			health := evaluateClusterHealth(tt.input)
+			if health.Status != tt.expected {
+				t.Errorf("Expected status %s, got %s (reasons: %v)", tt.expected, health.Status, health.Reasons)
+			}
+			for check, status := range tt.checks {
+				if health.Checks[check].Status != status {
+					t.Errorf("Expected check %s to be %s, got %s", check, status, health.Checks[check].Status)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHealthVerbose(t *testing.T) {
+	client := newTestMinIOClient(t)
+	client.snapshot = &Snapshot{Cluster: "test", UpdatedAt: time.Now(), LastError: "ServerInfo: dial tcp minio:9000: connection refused"}
+	client.breaker.Failure(errors.New("ServerInfo: dial tcp minio:9000: connection refused"))
+	// A recent probe is reused instead of calling MinIO
+	client.health.last = &healthProbe{
+		at:       time.Now(),
+		nodes:    []NodeInfo{{Endpoint: "node1:9000", State: "online"}},
+		nodesErr: errors.New("admin API timeout"),
+	}
+	sum := sha256.Sum256([]byte("s3cr3t"))
+	authenticator, err := newAuthenticatorFor(AuthConfig{BearerTokenHashes: []string{hex.EncodeToString(sum[:])}})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	client.authenticator.Store(authenticator)
+
+	tests := []struct {
+		name    string
+		token   string
+		verbose bool
+	}{
+		{"unauthenticated", "", false},
+		{"wrong token", "guess", false},
+		{"authenticated", "s3cr3t", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/health?verbose", nil)
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			client.handleHealth(recorder, request)
+
+			var report HealthReport
+			if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			cluster := report.Clusters["test"]
+			if verbose := len(cluster.Nodes) > 0 || cluster.Checks["admin_api"].Error != ""; verbose != tt.verbose {
+				t.Errorf("Expected verbose %v, got nodes %v and checks %v", tt.verbose, cluster.Nodes, cluster.Checks)
+			}
+			if details := cluster.LastError != "" || len(cluster.Reasons) > 0 || cluster.CircuitBreaker.LastError != ""; details != tt.verbose {
+				t.Errorf("Expected errors and reasons only when verbose, got %q %v %q", cluster.LastError, cluster.Reasons, cluster.CircuitBreaker.LastError)
+			}
+			if cluster.NodesTotal != 1 {
+				t.Errorf("Expected the cached probe with 1 node, got %d", cluster.NodesTotal)
+			}
+		})
+	}
+}
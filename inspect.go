@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BucketInventoryEntry describes a discovered bucket and why it is or is not emitted as a target
+type BucketInventoryEntry struct {
+	Cluster    string            `json:"cluster"`
+	Name       string            `json:"name"`
+	Creation   string            `json:"creation"`
+	Versioning string            `json:"versioning,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Decision   BucketDecision    `json:"decision"`
+	Labels     map[string]string `json:"labels,omitempty"` // Labels emitted on /sd; only set for included buckets
+}
+
+// NodeInventoryEntry describes a discovered MinIO node
+type NodeInventoryEntry struct {
+	Cluster  string `json:"cluster"`
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+	Pools    []int  `json:"pools,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// bucketInventory builds the bucket inventory of a snapshot using the given filter
+func (m *MinIOClient) bucketInventory(snapshot *Snapshot, filter BucketFilter) []BucketInventoryEntry {
+	entries := make([]BucketInventoryEntry, 0, len(snapshot.Buckets))
+	for _, bucket := range snapshot.Buckets {
+		metadata := snapshot.Metadata[bucket.Name]
+		entry := BucketInventoryEntry{
+			Cluster:    snapshot.Cluster,
+			Name:       bucket.Name,
+			Creation:   bucket.CreationDate.Format(time.RFC3339),
+			Versioning: metadata.Versioning,
+			Owner:      metadata.Owner,
+			Tags:       metadata.Tags,
+			Decision:   filter.Decide(bucket.Name),
+		}
+		if entry.Decision.Included {
+			entry.Labels = m.bucketLabels(bucket)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// nodeInventory builds the node inventory of a snapshot
+func nodeInventory(snapshot *Snapshot) []NodeInventoryEntry {
+	entries := make([]NodeInventoryEntry, 0, len(snapshot.Nodes))
+	for _, node := range snapshot.Nodes {
+		entries = append(entries, NodeInventoryEntry{
+			Cluster:  snapshot.Cluster,
+			Endpoint: node.Endpoint,
+			State:    node.State,
+			Pools:    node.Pools,
+			Version:  node.Version,
+		})
+	}
+	return entries
+}
+
+// wantsCSV reports whether the client asked for CSV via ?format=csv or the Accept header
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// formatLabels renders labels as sorted name=value pairs separated by semicolons
+func formatLabels(labels map[string]string) string {
+	return strings.Join(labelPairs(labels), ";")
+}
+
+// handleBuckets handles the /api/v1/buckets endpoint listing every discovered bucket with its filter decision
+func (m *MinIOClient) handleBuckets(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("Bucket inventory request from %s", r.RemoteAddr)
+
+	snapshot := m.Discover(r.Context())
+	entries, ok := paginate(w, r, m.bucketInventory(snapshot, m.bucketFilter()))
+	if !ok {
+		return
+	}
+
+	if !wantsCSV(r) {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"cluster", "bucket", "creation", "versioning", "owner", "included", "rule", "reason", "labels"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.Cluster,
+			entry.Name,
+			entry.Creation,
+			entry.Versioning,
+			entry.Owner,
+			strconv.FormatBool(entry.Decision.Included),
+			entry.Decision.Rule,
+			entry.Decision.Reason,
+			formatLabels(entry.Labels),
+		})
+	}
+	writer.Flush()
+}
+
+// handleNodes handles the /api/v1/nodes endpoint listing every discovered node
+func (m *MinIOClient) handleNodes(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("Node inventory request from %s", r.RemoteAddr)
+
+	snapshot := m.Discover(r.Context())
+	entries, ok := paginate(w, r, nodeInventory(snapshot))
+	if !ok {
+		return
+	}
+
+	if !wantsCSV(r) {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"cluster", "endpoint", "state", "pools", "version"})
+	for _, entry := range entries {
+		pools := make([]string, 0, len(entry.Pools))
+		for _, pool := range entry.Pools {
+			pools = append(pools, strconv.Itoa(pool))
+		}
+		writer.Write([]string{entry.Cluster, entry.Endpoint, entry.State, strings.Join(pools, ","), entry.Version})
+	}
+	writer.Flush()
+}
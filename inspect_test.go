@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestBucketFilterDecide(t *testing.T) {
+	filter := BucketFilter{Pattern: "prod-*", ExcludePattern: "*tmp*"}
+
+	tests := []struct {
+		bucket   string
+		included bool
+		rule     string
+	}{
+		{"prod-payments", true, RuleBucketPattern},
+		{"dev-payments", false, RuleBucketPattern},
+		{"prod-tmp", false, RuleBucketExcludePattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			decision := filter.Decide(tt.bucket)
+			if decision.Included != tt.included || decision.Rule != tt.rule {
+				t.Errorf("Expected included=%v rule=%s, got %+v", tt.included, tt.rule, decision)
+			}
+		})
+	}
+}
+
+func TestHandleBuckets(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.BucketPattern = "prod-*"
+	})
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Buckets: []minio.BucketInfo{
+			{Name: "prod-payments", CreationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Name: "dev-payments", CreationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	client.handleBuckets(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/buckets", nil))
+
+	var entries []BucketInventoryEntry
+	if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(entries))
+	}
+	if !entries[0].Decision.Included || entries[0].Labels["sd_bucket"] != "prod-payments" {
+		t.Errorf("Expected prod-payments to be included with labels, got %+v", entries[0])
+	}
+	if entries[1].Decision.Included || entries[1].Labels != nil {
+		t.Errorf("Expected dev-payments to be excluded without labels, got %+v", entries[1])
+	}
+
+	recorder = httptest.NewRecorder()
+	client.handleBuckets(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/buckets?format=csv", nil))
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d records", len(records))
+	}
+	if records[2][1] != "dev-payments" || records[2][5] != "false" || records[2][6] != RuleBucketPattern {
+		t.Errorf("Unexpected CSV row: %v", records[2])
+	}
+}
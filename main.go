@@ -3,14 +3,21 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -21,32 +28,136 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// ConfigFile represents the YAML configuration file structure
+// ConfigFile represents the YAML configuration file structure. Settings left out are nil
+// and fall back to the environment and defaults.
 type ConfigFile struct {
-	MinIOEndpoint        string `yaml:"minio_endpoint"`
-	MinIOAccessKey       string `yaml:"minio_access_key"`
-	MinIOSecretKey       string `yaml:"minio_secret_key"`
-	MinIOUseSSL          bool   `yaml:"minio_use_ssl"`
-	ListenAddr           string `yaml:"listen_addr"`
-	ScrapeInterval       string `yaml:"scrape_interval"`
-	MetricsPath          string `yaml:"metrics_path"`
-	BucketPattern        string `yaml:"bucket_pattern"`
-	BucketExcludePattern string `yaml:"bucket_exclude_pattern"`
+	Profile              *string `yaml:"profile"` // Profile applied unless one is selected by flag or environment
+	MinIOEndpoint        *string `yaml:"minio_endpoint"`
+	MinIOAccessKey       *string `yaml:"minio_access_key"`
+	MinIOSecretKey       *string `yaml:"minio_secret_key"`
+	MinIOAccessKeyFile   *string `yaml:"minio_access_key_file"`
+	MinIOSecretKeyFile   *string `yaml:"minio_secret_key_file"`
+	MinIOUseSSL          *bool   `yaml:"minio_use_ssl"`
+	ListenAddr           *string `yaml:"listen_addr"`
+	WebConfigFile        *string `yaml:"web_config_file"`
+	ShutdownTimeout      *string `yaml:"shutdown_timeout"`
+	ReadinessMaxAge      *string `yaml:"readiness_max_age"`
+	ScrapeInterval       *string `yaml:"scrape_interval"`
+	ScrapeTimeout        *string `yaml:"scrape_timeout"`
+	MetricsPath          *string `yaml:"metrics_path"`
+	BucketPattern        *string `yaml:"bucket_pattern"`
+	BucketExcludePattern *string `yaml:"bucket_exclude_pattern"`
+	ClusterName          *string `yaml:"cluster_name"`
+	BucketOwnerTag       *string `yaml:"bucket_owner_tag"`
+
+	// Query parameters /sd callers may use to scope target groups
+	SDAllowedParams []string `yaml:"sd_allowed_params"`
+	ShardLabels     []string `yaml:"shard_labels"`
+
+	// Generated Prometheus configuration
+	ExternalURL              *string `yaml:"external_url"`
+	PrometheusSDTokenFile    *string `yaml:"prometheus_sd_token_file"`
+	PrometheusMinIOTokenFile *string `yaml:"prometheus_minio_token_file"`
+	VMAgentSeriesLimit       *int    `yaml:"vmagent_series_limit"`
+	TelegrafMinIOTokenFile   *string `yaml:"telegraf_minio_token_file"`
+	AlloyForwardTo           *string `yaml:"alloy_forward_to"`
+
+	// file_sd output
+	FileSDDir    *string `yaml:"file_sd_dir"`
+	FileSDFormat *string `yaml:"file_sd_format"`
+
+	// Consul catalog API emulation
+	ConsulListenAddr *string `yaml:"consul_listen_addr"`
+
+	// Embedded DNS responder
+	DNSListenAddr *string `yaml:"dns_listen_addr"`
+	DNSDomain     *string `yaml:"dns_domain"`
+
+	// MinIO call timeouts and failure handling
+	ServerInfoTimeout       *string `yaml:"server_info_timeout"`
+	ListBucketsTimeout      *string `yaml:"list_buckets_timeout"`
+	EnrichmentTimeout       *string `yaml:"enrichment_timeout"`
+	BucketEnrichment        *bool   `yaml:"bucket_enrichment"`
+	EnrichmentWorkers       *int    `yaml:"enrichment_workers"`
+	EnrichmentDeadline      *string `yaml:"enrichment_deadline"`
+	BreakerFailureThreshold *int    `yaml:"breaker_failure_threshold"`
+	BackoffInitial          *string `yaml:"backoff_initial"`
+	BackoffMax              *string `yaml:"backoff_max"`
+
+	LogLevel *string `yaml:"log_level"`
+
+	// Authentication for the HTTP endpoints
+	Auth AuthConfig `yaml:"auth"`
+
+	// Scrape settings of individual jobs, overriding the global ones
+	Jobs map[string]JobConfig `yaml:"jobs"`
+
+	// Named profiles overriding the settings above, merged in when loading the file
+	Profiles map[string]ConfigFile `yaml:"profiles"`
+
+	profile     string   // Profile merged into the settings
+	profileKeys []string // Top-level keys set by the profile
 }
 
 // Config holds the application configuration
 type Config struct {
+	Profile              string // Profile of the config file merged into its base settings
 	MinIOEndpoint        string
 	MinIOAccessKey       string
 	MinIOSecretKey       string
+	MinIOAccessKeyFile   string // File the access key is read from, e.g. a mounted Kubernetes secret
+	MinIOSecretKeyFile   string // File the secret key is read from
 	MinIOUseSSL          bool
 	ListenAddr           string
+	WebConfigFile        string        // Prometheus style web config file with TLS and basic auth settings
+	ShutdownTimeout      time.Duration // Time allowed for in-flight requests to finish on SIGTERM/SIGINT
+	ReadinessMaxAge      time.Duration // Snapshot age beyond which /-/ready reports not ready (0 disables the check)
 	ScrapeInterval       time.Duration
+	ScrapeTimeout        time.Duration // At most ScrapeInterval
 	MetricsPath          string
-	BucketPattern        string // Wildcard pattern for bucket filtering
-	BucketExcludePattern string // Pattern to exclude buckets
+	BucketPattern        string           // Wildcard pattern for bucket filtering
+	BucketExcludePattern string           // Pattern to exclude buckets
+	ClusterName          string           // Name used to identify the MinIO cluster in health and metrics output
+	BucketOwnerTag       string           // Bucket tag holding the owner reported in eos_sd_bucket_info
+	TLSEnabled           bool             // Set when the web config file enables TLS on the listener
+	WebConfigFiles       []string         // Certificate, key and password files named in the web config file
+	TLSServer            *TLSServerConfig // TLS settings of the web config file, nil without TLS
 
-	DefaultScrapeConfig ScrapeConfig
+	SDAllowedParams []string // Scoping query parameters /sd callers may pass (e.g., bucket_pattern, label.*)
+	ShardLabels     []string // Labels hashed to assign target groups to shards, as in Prometheus hashmod
+
+	ExternalURL              string // URL Prometheus uses to reach this service (derived from ListenAddr if empty)
+	PrometheusSDTokenFile    string // Bearer token file Prometheus uses to authenticate against /sd
+	PrometheusMinIOTokenFile string // Bearer token file Prometheus uses to scrape MinIO v3 metrics
+	VMAgentSeriesLimit       int    // series_limit set on generated vmagent jobs (0 omits it)
+	TelegrafMinIOTokenFile   string // Bearer token file Telegraf uses to scrape MinIO v3 metrics
+	AlloyForwardTo           string // Receiver expression the generated Alloy prometheus.scrape components forward to
+
+	FileSDDir    string // Directory receiving one file_sd file per job (disabled if empty)
+	FileSDFormat string // file_sd file format, json or yaml
+
+	ConsulListenAddr string // Address of the emulated Consul catalog API (disabled if empty)
+
+	DNSListenAddr string // UDP and TCP address of the embedded DNS responder (disabled if empty)
+	DNSDomain     string // Domain the DNS responder is authoritative for
+
+	ServerInfoTimeout       time.Duration // Timeout for admin ServerInfo calls
+	ListBucketsTimeout      time.Duration // Timeout for S3 ListBuckets calls
+	EnrichmentTimeout       time.Duration // Timeout for per-bucket metadata calls
+	BucketEnrichment        bool          // Fetch versioning and tags of every bucket on refresh
+	EnrichmentWorkers       int           // Buckets whose metadata is fetched concurrently
+	EnrichmentDeadline      time.Duration // Overall time allowed for fetching bucket metadata per refresh
+	BreakerFailureThreshold int           // Consecutive failures before the circuit breaker opens
+	BackoffInitial          time.Duration // First open period of the circuit breaker
+	BackoffMax              time.Duration // Upper bound for the exponential backoff
+
+	LogLevel string // logrus level, applied at startup and on reload
+
+	Auth AuthConfig // Authentication for the HTTP endpoints (config file only)
+
+	Jobs map[string]JobSettings // Per-job scrape setting overrides (config file only)
+
+	Sources map[string]string // Source of each setting (default, file, profile, env or flag), keyed by config file key
 }
 
 // ScrapeConfig represents a Prometheus scrape configuration
@@ -80,15 +191,47 @@ type ServiceDiscoveryResponse struct {
 	Labels  map[string]string `json:"labels"`
 }
 
-// MinIOClient wraps the MinIO client
-type MinIOClient struct {
+// NodeInfo describes a MinIO server discovered through the admin API
+type NodeInfo struct {
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+	Pools    []int  `json:"pools,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// BucketMetadata holds per-bucket details fetched in addition to ListBuckets
+type BucketMetadata struct {
+	Versioning string            `json:"versioning"`
+	Owner      string            `json:"owner,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// clientState is the configuration and the MinIO clients built from it, swapped as a unit on reload
+type clientState struct {
+	config Config
 	client *minio.Client
 	admin  *madmin.AdminClient
-	config Config
 }
 
-// NewMinIOClient creates a new MinIO client
-func NewMinIOClient(config Config) (*MinIOClient, error) {
+// MinIOClient wraps the MinIO client
+type MinIOClient struct {
+	state   atomic.Pointer[clientState]
+	breaker *CircuitBreaker
+	metrics *Metrics
+	changes *ChangeLog
+
+	snapshotMu sync.RWMutex
+	snapshot   *Snapshot
+	refreshMu  sync.Mutex
+
+	shuttingDown  atomic.Bool
+	lastReload    atomic.Pointer[ReloadStatus]
+	authenticator atomic.Pointer[Authenticator] // Nil when authentication is disabled
+	health        healthProber
+}
+
+// newClientState creates the S3 and admin clients for a configuration
+func newClientState(config Config) (*clientState, error) {
 	client, err := minio.New(config.MinIOEndpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(config.MinIOAccessKey, config.MinIOSecretKey, ""),
 		Secure: config.MinIOUseSSL,
@@ -103,32 +246,155 @@ func NewMinIOClient(config Config) (*MinIOClient, error) {
 		return nil, fmt.Errorf("failed to create MinIO admin client: %w", err)
 	}
 
-	return &MinIOClient{
-		client: client,
-		admin:  admin,
-		config: config,
-	}, nil
+	return &clientState{config: config, client: client, admin: admin}, nil
+}
+
+// NewMinIOClient creates a new MinIO client
+func NewMinIOClient(config Config) (*MinIOClient, error) {
+	state, err := newClientState(config)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MinIOClient{
+		breaker: NewCircuitBreaker(config.BreakerFailureThreshold, config.BackoffInitial, config.BackoffMax),
+		changes: NewChangeLog(maxChanges),
+	}
+	authenticator, err := newAuthenticatorFor(config.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
+	m.authenticator.Store(authenticator)
+	m.state.Store(state)
+	m.lastReload.Store(&ReloadStatus{Success: true, Time: time.Now(), Trigger: ReloadTriggerStartup})
+	m.metrics = NewMetrics(m)
+	return m, nil
+}
+
+// cfg returns the configuration currently in effect
+func (m *MinIOClient) cfg() *Config {
+	return &m.state.Load().config
+}
+
+// callMinIO runs fn with a per-call timeout, guarded by the cluster circuit breaker.
+// Calls abandoned because the inbound request went away are not counted as failures.
+func (m *MinIOClient) callMinIO(ctx context.Context, call string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if err := m.breaker.Allow(); err != nil {
+		logrus.Debugf("Skipping %s for cluster %s: %v", call, m.cfg().ClusterName, err)
+		m.metrics.ObserveCall(m.cfg().ClusterName, call, 0, err)
+		return err
+	}
+
+	err := m.timedCall(ctx, call, timeout, fn)
+	switch {
+	case err == nil:
+		m.breaker.Success()
+	case ctx.Err() != nil:
+		m.breaker.Abandon()
+	default:
+		m.breaker.Failure(fmt.Errorf("%s: %w", call, err))
+		if m.breaker.State() == BreakerOpen {
+			logrus.Warnf("Circuit breaker for cluster %s is open after %s failure: %v", m.cfg().ClusterName, call, err)
+		}
+	}
+	return err
+}
+
+// timedCall runs fn with a per-call timeout and records it in the call metrics, without
+// involving the circuit breaker
+func (m *MinIOClient) timedCall(ctx context.Context, call string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := fn(ctx)
+	m.metrics.ObserveCall(m.cfg().ClusterName, call, time.Since(start), err)
+	return err
 }
 
 // ListBuckets retrieves all buckets from MinIO
 func (m *MinIOClient) ListBuckets(ctx context.Context) ([]minio.BucketInfo, error) {
-	buckets, err := m.client.ListBuckets(ctx)
+	var buckets []minio.BucketInfo
+	err := m.callMinIO(ctx, "ListBuckets", m.cfg().ListBucketsTimeout, func(ctx context.Context) error {
+		var err error
+		buckets, err = m.state.Load().client.ListBuckets(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list buckets: %w", err)
 	}
 	return buckets, nil
 }
 
+// GetBucketMetadata retrieves versioning status and tags of a bucket.
+// Buckets without tags are not an error; the owner is taken from the configured owner tag.
+// The calls bypass the circuit breaker: bucket metadata is optional, and a key that may
+// list buckets but not read their settings must not block discovery. Settings the key may
+// not read are left empty.
+func (m *MinIOClient) GetBucketMetadata(ctx context.Context, bucket string) (BucketMetadata, error) {
+	metadata := BucketMetadata{Versioning: "Unversioned"}
+
+	err := m.timedCall(ctx, "GetBucketVersioning", m.cfg().EnrichmentTimeout, func(ctx context.Context) error {
+		versioning, err := m.state.Load().client.GetBucketVersioning(ctx, bucket)
+		if err != nil {
+			if isAccessDenied(err) {
+				metadata.Versioning = "" // Unknown rather than unversioned
+				return nil
+			}
+			return err
+		}
+		if versioning.Status != "" {
+			metadata.Versioning = versioning.Status
+		}
+		return nil
+	})
+	if err != nil {
+		return metadata, fmt.Errorf("failed to get versioning for bucket %s: %w", bucket, err)
+	}
+
+	err = m.timedCall(ctx, "GetBucketTagging", m.cfg().EnrichmentTimeout, func(ctx context.Context) error {
+		bucketTags, err := m.state.Load().client.GetBucketTagging(ctx, bucket)
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchTagSet" || isAccessDenied(err) {
+				return nil
+			}
+			return err
+		}
+		metadata.Tags = bucketTags.ToMap()
+		return nil
+	})
+	if err != nil {
+		return metadata, fmt.Errorf("failed to get tags for bucket %s: %w", bucket, err)
+	}
+
+	metadata.Owner = metadata.Tags[m.cfg().BucketOwnerTag]
+	return metadata, nil
+}
+
+// isAccessDenied reports whether MinIO refused a call for lack of permissions. Bucket
+// metadata the key may not read is treated as absent rather than as a failure.
+func isAccessDenied(err error) bool {
+	return minio.ToErrorResponse(err).Code == "AccessDenied"
+}
+
 // GetClusterNodes retrieves all nodes in the MinIO cluster using admin API
-func (m *MinIOClient) GetClusterNodes(ctx context.Context) ([]string, error) {
-	logrus.Debugf("Starting cluster node discovery for endpoint: %s", m.config.MinIOEndpoint)
+func (m *MinIOClient) GetClusterNodes(ctx context.Context) ([]NodeInfo, error) {
+	logrus.Debugf("Starting cluster node discovery for endpoint: %s", m.cfg().MinIOEndpoint)
 
 	// Use madmin client to get server info (same as 'mc admin info')
-	logrus.Debugf("Calling madmin.ServerInfo() for endpoint: %s", m.config.MinIOEndpoint)
-	serverInfo, err := m.admin.ServerInfo(ctx)
+	logrus.Debugf("Calling madmin.ServerInfo() for endpoint: %s", m.cfg().MinIOEndpoint)
+	var serverInfo madmin.InfoMessage
+	err := m.callMinIO(ctx, "ServerInfo", m.cfg().ServerInfoTimeout, func(ctx context.Context) error {
+		var err error
+		serverInfo, err = m.state.Load().admin.ServerInfo(ctx)
+		return err
+	})
 	if err != nil {
-		logrus.Warnf("Failed to get server info via madmin for endpoint %s: %v", m.config.MinIOEndpoint, err)
-		return []string{}, nil
+		logrus.Warnf("Failed to get server info via madmin for endpoint %s: %v", m.cfg().MinIOEndpoint, err)
+		return []NodeInfo{}, err
 	}
 
 	logrus.Debugf("Successfully retrieved server info: mode=%s, deploymentID=%s, region=%s",
@@ -136,7 +402,7 @@ func (m *MinIOClient) GetClusterNodes(ctx context.Context) ([]string, error) {
 	logrus.Debugf("Server info has %d servers", len(serverInfo.Servers))
 
 	// Extract node endpoints from server info
-	var nodes []string
+	var nodes []NodeInfo
 	for serverIndex, server := range serverInfo.Servers {
 		logrus.Debugf("Processing server %d: endpoint=%s, state=%s, isLeader=%t, poolNumbers=%v",
 			serverIndex, server.Endpoint, server.State, server.IsLeader, server.PoolNumbers)
@@ -148,7 +414,12 @@ func (m *MinIOClient) GetClusterNodes(ctx context.Context) ([]string, error) {
 				endpoint = endpoint + ":9000"
 				logrus.Debugf("Added default port to endpoint %s -> %s", server.Endpoint, endpoint)
 			}
-			nodes = append(nodes, endpoint)
+			nodes = append(nodes, NodeInfo{
+				Endpoint: endpoint,
+				State:    server.State,
+				Pools:    server.PoolNumbers,
+				Version:  server.Version,
+			})
 			logrus.Debugf("Added node %s from server %d", endpoint, serverIndex)
 		} else {
 			logrus.Debugf("Skipping server %d with empty endpoint", serverIndex)
@@ -157,12 +428,12 @@ func (m *MinIOClient) GetClusterNodes(ctx context.Context) ([]string, error) {
 
 	// If no nodes found in server info, return empty list
 	if len(nodes) == 0 {
-		logrus.Warnf("No nodes found in server info response from endpoint %s", m.config.MinIOEndpoint)
+		logrus.Warnf("No nodes found in server info response from endpoint %s", m.cfg().MinIOEndpoint)
 		logrus.Debugf("Server info servers: %+v", serverInfo.Servers)
-		return []string{}, nil
+		return []NodeInfo{}, nil
 	}
 
-	logrus.Infof("Successfully discovered %d cluster nodes from admin API endpoint %s: %v", len(nodes), m.config.MinIOEndpoint, nodes)
+	logrus.Infof("Successfully discovered %d cluster nodes from admin API endpoint %s: %v", len(nodes), m.cfg().MinIOEndpoint, nodes)
 	logrus.Debugf("Final node list: %v", nodes)
 	return nodes, nil
 }
@@ -201,48 +472,38 @@ func (m *MinIOClient) GenerateScrapeConfigs(ctx context.Context) ([]ScrapeConfig
 	// Bucket listing is done dynamically in handleServiceDiscovery when the job is requested
 
 	var configs []ScrapeConfig
+	for _, job := range scrapeJobs {
+		settings := m.cfg().jobSettings(job)
+		labels := m.jobLabels(job)
+		labels["instance"] = m.cfg().MinIOEndpoint
+		if job == "minio-buckets" {
+			labels["bucket_pattern"] = "*"
+		}
 
-	// Generate config for MinIO server metrics (global)
-	// Note: The actual targets will be dynamically discovered in handleServiceDiscovery
-	serverConfig := m.config.DefaultScrapeConfig
-	serverConfig.JobName = "minio-server"
-	serverConfig.StaticConfigs = []StaticConfig{
-		{
-			Targets: []string{m.config.MinIOEndpoint}, // Placeholder - will be replaced dynamically
-			Labels: map[string]string{
-				"__metrics_path__": "/minio/metrics/v3",
-				"__scheme__":       m.getScheme(),
-				"instance":         m.config.MinIOEndpoint,
-				"job":              "minio-server",
+		// The actual targets are discovered dynamically in handleServiceDiscovery
+		configs = append(configs, ScrapeConfig{
+			JobName: job,
+			StaticConfigs: []StaticConfig{
+				{Targets: []string{m.cfg().MinIOEndpoint}, Labels: labels},
 			},
-		},
-	}
-	configs = append(configs, serverConfig)
-
-	// Generate config for all buckets in a single job
-	// Always generate this job, even if no buckets exist yet
-	bucketConfig := m.config.DefaultScrapeConfig
-	bucketConfig.JobName = "minio-buckets"
-	bucketConfig.StaticConfigs = []StaticConfig{
-		{
-			Targets: []string{m.config.MinIOEndpoint},
-			Labels: map[string]string{
-				"__metrics_path__": "/minio/metrics/v3/bucket/api",
-				"__scheme__":       m.getScheme(),
-				"instance":         m.config.MinIOEndpoint,
-				"job":              "minio-buckets",
-				"bucket_pattern":   "*",
-			},
-		},
+			MetricsPath:    settings.MetricsPath,
+			ScrapeInterval: formatDuration(settings.ScrapeInterval),
+			ScrapeTimeout:  formatDuration(settings.ScrapeTimeout),
+			Scheme:         m.getScheme(),
+		})
 	}
-	configs = append(configs, bucketConfig)
 
 	return configs, nil
 }
 
 // getScheme returns the scheme based on SSL configuration
 func (m *MinIOClient) getScheme() string {
-	if m.config.MinIOUseSSL {
+	return m.cfg().scheme()
+}
+
+// scheme returns the scheme of the MinIO endpoint
+func (c Config) scheme() string {
+	if c.MinIOUseSSL {
 		return "https"
 	}
 	return "http"
@@ -255,40 +516,95 @@ func matchPattern(str, pattern string) bool {
 		return true
 	}
 
-	// Simple glob pattern matching
-	// Convert glob pattern to regex
-	regexPattern := "^" + strings.ReplaceAll(pattern, "*", ".*") + "$"
-	regexPattern = strings.ReplaceAll(regexPattern, "?", ".")
-
-	matched, err := regexp.MatchString(regexPattern, str)
+	matched, err := regexp.MatchString(globRegexp(pattern), str)
 	if err != nil {
 		return false
 	}
 	return matched
 }
 
-// filterBuckets filters buckets based on include/exclude patterns
-func (m *MinIOClient) filterBuckets(buckets []minio.BucketInfo) []minio.BucketInfo {
-	if m.config.BucketPattern == "*" && m.config.BucketExcludePattern == "" {
-		return buckets // No filtering needed
-	}
+// globRegexp converts a simple glob pattern (* and ?) to an anchored regular expression
+func globRegexp(pattern string) string {
+	regexPattern := "^" + strings.ReplaceAll(pattern, "*", ".*") + "$"
+	return strings.ReplaceAll(regexPattern, "?", ".")
+}
 
-	var filtered []minio.BucketInfo
-	for _, bucket := range buckets {
-		// Check include pattern
-		if !matchPattern(bucket.Name, m.config.BucketPattern) {
-			continue
+// bucketLabels returns the labels of the minio-buckets target group for a bucket
+func (m *MinIOClient) bucketLabels(bucket minio.BucketInfo) map[string]string {
+	return m.cfg().bucketLabels(bucket)
+}
+
+// bucketLabels returns the labels of the minio-buckets target group for a bucket with this configuration
+func (c Config) bucketLabels(bucket minio.BucketInfo) map[string]string {
+	labels := c.jobLabels("minio-buckets")
+	labels["__metrics_path__"] = fmt.Sprintf("%s/%s", strings.TrimSuffix(labels["__metrics_path__"], "/"), bucket.Name)
+	labels["sd_bucket"] = bucket.Name
+	labels["sd_bucket_creation"] = bucket.CreationDate.Format(time.RFC3339)
+	return labels
+}
+
+// buildTargetGroups converts a job's scrape config into service discovery target groups,
+// using the nodes and buckets of the snapshot and the given bucket filter
+func (m *MinIOClient) buildTargetGroups(targetConfig ScrapeConfig, snapshot *Snapshot, filter BucketFilter) []ServiceDiscoveryResponse {
+	return buildTargetGroupsFor(*m.cfg(), targetConfig, snapshot, filter)
+}
+
+// buildTargetGroupsFor builds the target groups of a job with the job settings of the given
+// configuration, e.g. a candidate one that is not in effect
+func buildTargetGroupsFor(config Config, targetConfig ScrapeConfig, snapshot *Snapshot, filter BucketFilter) []ServiceDiscoveryResponse {
+	var response []ServiceDiscoveryResponse
+
+	switch targetConfig.JobName {
+	case "minio-buckets":
+		// One target group per bucket, with all nodes as targets
+		for _, bucket := range filter.Apply(snapshot.Buckets) {
+			response = append(response, ServiceDiscoveryResponse{
+				Targets: snapshot.Targets(),
+				Labels:  config.bucketLabels(bucket),
+			})
 		}
+	case "minio-server":
+		// A single target group with all nodes
+		response = append(response, ServiceDiscoveryResponse{
+			Targets: snapshot.Targets(),
+			Labels:  config.jobLabels("minio-server"),
+		})
+	default:
+		// For other jobs, use the standard approach
+		for _, staticConfig := range targetConfig.StaticConfigs {
+			for _, target := range staticConfig.Targets {
+				// Copy the labels
+				labels := make(map[string]string)
+				for k, v := range staticConfig.Labels {
+					labels[k] = v
+				}
 
-		// Check exclude pattern
-		if m.config.BucketExcludePattern != "" && matchPattern(bucket.Name, m.config.BucketExcludePattern) {
-			continue
+				response = append(response, ServiceDiscoveryResponse{
+					Targets: []string{target},
+					Labels:  labels,
+				})
+			}
 		}
+	}
+
+	return response
+}
 
-		filtered = append(filtered, bucket)
+// targetGroups returns the target groups served on /sd for a job with the configured bucket
+// filter and records them in the self-monitoring metrics. Other consumers of the target
+// groups (Consul catalog, file_sd, exporters, UI) use buildTargetGroups, so that the
+// metrics reflect what /sd served.
+func (m *MinIOClient) targetGroups(targetConfig ScrapeConfig, snapshot *Snapshot) []ServiceDiscoveryResponse {
+	response := m.buildTargetGroups(targetConfig, snapshot, m.bucketFilter())
+
+	if targetConfig.JobName == "minio-buckets" {
+		logrus.Infof("Found %d buckets, applying pattern '%s' and exclude '%s'", len(snapshot.Buckets), m.cfg().BucketPattern, m.cfg().BucketExcludePattern)
+		logrus.Infof("After filtering, %d buckets remain", len(response))
+		m.metrics.ObserveFilteredBuckets(m.cfg().ClusterName, len(response))
 	}
 
-	return filtered
+	m.metrics.ObserveTargets(targetConfig.JobName, response)
+	return response
 }
 
 // handleServiceDiscovery handles the /sd endpoint for Prometheus service discovery
@@ -305,6 +621,14 @@ func (m *MinIOClient) handleServiceDiscovery(w http.ResponseWriter, r *http.Requ
 
 	logrus.Infof("Service discovery request for job '%s' from %s", jobName, r.RemoteAddr)
 
+	// Other query parameters narrow the target groups, if the allow-list permits
+	scope, status, err := m.parseSDScope(r.URL.Query())
+	if err != nil {
+		logrus.Warnf("Rejected service discovery request from %s: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
 	// Generate all scrape configs
 	configs, err := m.GenerateScrapeConfigs(ctx)
 	if err != nil {
@@ -327,67 +651,14 @@ func (m *MinIOClient) handleServiceDiscovery(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Convert to service discovery format
+	// Nodes and buckets come from the discovery snapshot; if the cluster is still
+	// starting the snapshot is empty, which still lets Prometheus discover the job
+	snapshot := m.Discover(ctx)
 	var response []ServiceDiscoveryResponse
-
-	// Special handling for minio-buckets job - return all buckets
-	if jobName == "minio-buckets" {
-		// Get cluster nodes for better monitoring
-		nodes, _ := m.GetClusterNodes(ctx)
-
-		buckets, err := m.ListBuckets(ctx)
-		if err != nil {
-			logrus.Warnf("Failed to list buckets (cluster may still be starting): %v", err)
-			// Don't return error, just return empty response for now
-			// This allows Prometheus to discover the job even when cluster is starting
-		} else {
-			// Apply wildcard filtering
-			filteredBuckets := m.filterBuckets(buckets)
-			logrus.Infof("Found %d buckets, applying pattern '%s' and exclude '%s'", len(buckets), m.config.BucketPattern, m.config.BucketExcludePattern)
-			logrus.Infof("After filtering, %d buckets remain", len(filteredBuckets))
-
-			for _, bucket := range filteredBuckets {
-				// Create one configuration with all nodes as targets for this bucket
-				response = append(response, ServiceDiscoveryResponse{
-					Targets: nodes,
-					Labels: map[string]string{
-						"__metrics_path__":   fmt.Sprintf("/minio/metrics/v3/bucket/api/%s", bucket.Name),
-						"__scheme__":         m.getScheme(),
-						"job":                "minio-buckets",
-						"sd_bucket":          bucket.Name,
-						"sd_bucket_creation": bucket.CreationDate.Format(time.RFC3339),
-					},
-				})
-			}
-		}
-	} else if jobName == "minio-server" {
-		// For minio-server job, get cluster nodes and create one configuration with all nodes
-		nodes, _ := m.GetClusterNodes(ctx)
-
-		response = append(response, ServiceDiscoveryResponse{
-			Targets: nodes,
-			Labels: map[string]string{
-				"__metrics_path__": "/minio/metrics/v3",
-				"job":              "minio-server",
-				"__scheme__":       m.getScheme(),
-			},
-		})
+	if scope.scoped {
+		response = scope.targetGroups(m, *targetConfig, snapshot)
 	} else {
-		// For other jobs, use the standard approach
-		for _, staticConfig := range targetConfig.StaticConfigs {
-			for _, target := range staticConfig.Targets {
-				// Copy the labels
-				labels := make(map[string]string)
-				for k, v := range staticConfig.Labels {
-					labels[k] = v
-				}
-
-				response = append(response, ServiceDiscoveryResponse{
-					Targets: []string{target},
-					Labels:  labels,
-				})
-			}
-		}
+		response = m.targetGroups(*targetConfig, snapshot)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -412,211 +683,169 @@ func (m *MinIOClient) handleScrapeConfigs(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(configs)
 }
 
-// handleHealth handles the /health endpoint
-func (m *MinIOClient) handleHealth(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// handleHealthy handles the /-/healthy liveness endpoint; it only reports that the process is serving
+func (m *MinIOClient) handleHealthy(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "MinIO Prometheus Service Discovery is Healthy.")
+}
 
-	logrus.Debugf("Health check request from %s", r.RemoteAddr)
+// handleReady handles the /-/ready readiness endpoint. The service is ready once the first
+// discovery snapshot is loaded, as long as it is not older than ReadinessMaxAge and the
+// service is not shutting down. MinIO is never called from this handler.
+func (m *MinIOClient) handleReady(w http.ResponseWriter, r *http.Request) {
+	if m.shuttingDown.Load() {
+		http.Error(w, "Service is shutting down.", http.StatusServiceUnavailable)
+		return
+	}
 
-	// Test MinIO connection
-	_, err := m.client.ListBuckets(ctx)
-	if err != nil {
-		logrus.Warnf("Health check failed - MinIO connection error: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":    "unhealthy",
-			"error":     err.Error(),
-			"timestamp": time.Now().Format(time.RFC3339),
-		})
+	snapshot := m.currentSnapshot()
+	if snapshot == nil || snapshot.UpdatedAt.IsZero() {
+		http.Error(w, "Service is not ready: no discovery snapshot loaded yet.", http.StatusServiceUnavailable)
+		return
+	}
+
+	if age := time.Since(snapshot.UpdatedAt); m.cfg().ReadinessMaxAge > 0 && age > m.cfg().ReadinessMaxAge {
+		http.Error(w, fmt.Sprintf("Service is not ready: discovery snapshot is %v old (max %v).", age.Round(time.Second), m.cfg().ReadinessMaxAge), http.StatusServiceUnavailable)
 		return
 	}
 
-	logrus.Debugf("Health check passed - MinIO connection successful")
-	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
-	})
+	fmt.Fprintln(w, "MinIO Prometheus Service Discovery is Ready.")
 }
 
-// loadConfigFromFile loads configuration from a YAML file
-func loadConfigFromFile(filename string) (*ConfigFile, error) {
+// loadConfigFromFile loads configuration from a YAML file with the given profile, or the one
+// named in the file if empty, merged in, expanding environment variable references
+// in its values. Unknown keys are rejected with their line number.
+func loadConfigFromFile(filename, profile string) (*ConfigFile, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file %s: %w", filename, err)
 	}
 
 	var config ConfigFile
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", filename, err)
+	}
+	if node.Kind == 0 {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %s is selected but config file %s is empty", profile, filename)
+		}
+		return &config, nil // Empty file
+	}
+	if err := errors.Join(checkKnownKeys(node.Content[0], reflect.TypeFor[ConfigFile]())...); err != nil {
+		return nil, fmt.Errorf("invalid config file %s:\n%w", filename, err)
+	}
+	// Only the selected profile is expanded, so other profiles may reference unset variables
+	settings, profile, profileKeys, err := applyProfile(node.Content[0], profile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	if err := expandEnvNode(settings); err != nil {
+		return nil, fmt.Errorf("failed to expand config file %s: %w", filename, err)
+	}
+	if err := settings.Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", filename, err)
 	}
+	config.profile, config.profileKeys = profile, profileKeys
 
 	return &config, nil
 }
 
-// loadConfig loads configuration with priority: config file > command line arguments > environment variables > default values
-func loadConfig() Config {
-	// Define command line flags
+// loadConfig loads configuration with priority: command line flags > environment variables > config file > default values.
+// The returned loader re-reads the config file on reload.
+func loadConfig(args []string) (Config, *ConfigLoader, error) {
+	// Define command line flags; every setting of the config file also has a flag
+	flags := flag.NewFlagSet("minio-prometheus-sd", flag.ExitOnError)
 	var (
-		help                 = flag.Bool("help", false, "Show help information")
-		configFile           = flag.String("config-file", "config.yaml", "Path to configuration file (YAML)")
-		minioEndpoint        = flag.String("minio-endpoint", "", "MinIO server endpoint (e.g., localhost:9000)")
-		minioAccessKey       = flag.String("minio-access-key", "", "MinIO access key")
-		minioSecretKey       = flag.String("minio-secret-key", "", "MinIO secret key")
-		minioUseSSL          = flag.Bool("minio-use-ssl", false, "Use SSL for MinIO connection")
-		listenAddr           = flag.String("listen-addr", "", "Address to listen on (e.g., :8080)")
-		scrapeInterval       = flag.String("scrape-interval", "", "Scrape interval (e.g., 15s)")
-		metricsPath          = flag.String("metrics-path", "", "Metrics path (e.g., /minio/metrics/v3)")
-		bucketPattern        = flag.String("bucket-pattern", "", "Wildcard pattern for bucket inclusion")
-		bucketExcludePattern = flag.String("bucket-exclude-pattern", "", "Wildcard pattern for bucket exclusion")
-		logLevel             = flag.String("log-level", "info", "Log level (debug, info, warn, error, fatal, panic)")
+		help        = flags.Bool("help", false, "Show help information")
+		configFile  = flags.String("config-file", "config.yaml", "Path to configuration file (YAML)")
+		printConfig = flags.Bool("print-config", false, "Print the effective configuration with the source of each setting and exit")
 	)
+	registerConfigFlags(flags)
 
 	// Parse command line flags
-	flag.Parse()
-
-	// Set log level based on command line flag
-	level, err := logrus.ParseLevel(*logLevel)
-	if err != nil {
-		logrus.Warnf("Invalid log level '%s', using 'info' level. Valid levels: debug, info, warn, error, fatal, panic", *logLevel)
-		level = logrus.InfoLevel
-	}
-	logrus.SetLevel(level)
-	logrus.Infof("Log level set to: %s", level.String())
+	flags.Parse(args)
 
 	// Show help if requested
 	if *help {
 		fmt.Println("MinIO Prometheus Service Discovery")
 		fmt.Println("Usage:")
 		fmt.Println("  minio-prometheus-sd [flags]")
+		fmt.Println("  minio-prometheus-sd prometheus-config [flags]   Print the Prometheus scrape configs and exit")
+		fmt.Println("  minio-prometheus-sd file-sd -file-sd-dir=DIR [flags]   Write the file_sd target files once and exit")
+		fmt.Println("  minio-prometheus-sd vmagent-config [flags]      Print the vmagent scrape configs and exit")
+		fmt.Println("  minio-prometheus-sd telegraf-config [flags]     Print Telegraf inputs.prometheus blocks and exit")
+		fmt.Println("  minio-prometheus-sd otelcol-config [flags]      Print the OpenTelemetry Collector prometheus receiver and exit")
+		fmt.Println("  minio-prometheus-sd alloy-config [flags]        Print the Grafana Alloy components and exit")
+		fmt.Println("  minio-prometheus-sd check-config [flags]        Validate the configuration and exit non-zero on problems")
+		fmt.Println("  minio-prometheus-sd config-schema               Print the JSON Schema of the config file and exit")
 		fmt.Println("")
 		fmt.Println("Flags:")
-		flag.PrintDefaults()
+		flags.SetOutput(os.Stdout)
+		flags.PrintDefaults()
 		fmt.Println("")
 		fmt.Println("Configuration Priority (highest to lowest):")
-		fmt.Println("  1. Configuration file (YAML)")
-		fmt.Println("  2. Command line arguments")
-		fmt.Println("  3. Environment variables")
+		fmt.Println("  1. Command line arguments")
+		fmt.Println("  2. Environment variables (named after the setting, e.g. SCRAPE_INTERVAL)")
+		fmt.Println("  3. Configuration file (YAML), the selected profile over the base settings")
 		fmt.Println("  4. Default values")
 		fmt.Println("")
-		fmt.Println("Environment Variables (used if not specified elsewhere):")
-		fmt.Println("  MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, MINIO_USE_SSL")
-		fmt.Println("  LISTEN_ADDR, SCRAPE_INTERVAL, METRICS_PATH, BUCKET_PATTERN, BUCKET_EXCLUDE_PATTERN")
-		fmt.Println("")
 		fmt.Println("Examples:")
 		fmt.Println("  minio-prometheus-sd -config-file=myconfig.yaml")
 		fmt.Println("  minio-prometheus-sd -minio-endpoint=minio:9000 -minio-access-key=mykey")
 		fmt.Println("  minio-prometheus-sd -listen-addr=:9090 -bucket-pattern=prod-*")
+		fmt.Println("  minio-prometheus-sd -config-file=myconfig.yaml -print-config")
+		fmt.Println("  minio-prometheus-sd -config-file=myconfig.yaml -profile=prod")
 		os.Exit(0)
 	}
 
-	// Load configuration from file if it exists
-	var fileConfig *ConfigFile
+	// Load configuration from file; only the default config file may be missing, unless a
+	// profile of it is selected
+	explicitFile := false
+	flags.Visit(func(f *flag.Flag) { explicitFile = explicitFile || f.Name == "config-file" })
+	fileConfig := &ConfigFile{}
+	fileLoaded := false
+	profile := selectedProfile(flagValues(flags))
 	if *configFile != "" {
-		if config, err := loadConfigFromFile(*configFile); err == nil {
-			fileConfig = config
-			logrus.Infof("Configuration loaded from file: %s", *configFile)
-		} else {
-			logrus.Warnf("Failed to load config file %s: %v", *configFile, err)
-		}
-	}
-
-	// Create a default ConfigFile if none was loaded
-	if fileConfig == nil {
-		fileConfig = &ConfigFile{}
-	}
-
-	// Helper function to get value with priority: config file > command line > environment variable > default
-	getValue := func(fileValue *string, cmdValue *string, envKey, defaultValue string) string {
-		if fileValue != nil && *fileValue != "" {
-			return *fileValue
-		}
-		if *cmdValue != "" {
-			return *cmdValue
-		}
-		return getEnv(envKey, defaultValue)
-	}
-
-	// Helper function to get boolean value with priority: config file > command line > environment variable > default
-	getBoolValue := func(fileValue *bool, cmdValue *bool, envKey string, defaultValue bool) bool {
-		if fileValue != nil && *fileValue != defaultValue {
-			return *fileValue
-		}
-		if *cmdValue != defaultValue {
-			return *cmdValue
-		}
-		return getEnvAsBool(envKey, defaultValue)
-	}
-
-	// Helper function to get duration value with priority: config file > command line > environment variable > default
-	getDurationValue := func(fileValue *string, cmdValue *string, envKey string, defaultValue time.Duration) time.Duration {
-		if fileValue != nil && *fileValue != "" {
-			if duration, err := time.ParseDuration(*fileValue); err == nil {
-				return duration
-			}
-		}
-		if *cmdValue != "" {
-			if duration, err := time.ParseDuration(*cmdValue); err == nil {
-				return duration
+		loaded, err := loadConfigFromFile(*configFile, profile)
+		switch {
+		case err == nil:
+			fileConfig = loaded
+			fileLoaded = true
+			if loaded.profile != "" {
+				logrus.Infof("Configuration loaded from file: %s (profile %s)", *configFile, loaded.profile)
+			} else {
+				logrus.Infof("Configuration loaded from file: %s", *configFile)
 			}
+		case errors.Is(err, fs.ErrNotExist) && !explicitFile && profile == "":
+			logrus.Infof("No config file %s, using flags, environment variables and defaults", *configFile)
+		default:
+			return Config{}, nil, err
 		}
-		return getEnvAsDuration(envKey, defaultValue)
 	}
-
-	config := Config{
-		MinIOEndpoint:        getValue(&fileConfig.MinIOEndpoint, minioEndpoint, "MINIO_ENDPOINT", "localhost:9000"),
-		MinIOAccessKey:       getValue(&fileConfig.MinIOAccessKey, minioAccessKey, "MINIO_ACCESS_KEY", "minioadmin"),
-		MinIOSecretKey:       getValue(&fileConfig.MinIOSecretKey, minioSecretKey, "MINIO_SECRET_KEY", "minioadmin"),
-		MinIOUseSSL:          getBoolValue(&fileConfig.MinIOUseSSL, minioUseSSL, "MINIO_USE_SSL", false),
-		ListenAddr:           getValue(&fileConfig.ListenAddr, listenAddr, "LISTEN_ADDR", ":8080"),
-		ScrapeInterval:       getDurationValue(&fileConfig.ScrapeInterval, scrapeInterval, "SCRAPE_INTERVAL", 15*time.Second),
-		MetricsPath:          getValue(&fileConfig.MetricsPath, metricsPath, "METRICS_PATH", "/minio/metrics/v3"),
-		BucketPattern:        getValue(&fileConfig.BucketPattern, bucketPattern, "BUCKET_PATTERN", "*"),
-		BucketExcludePattern: getValue(&fileConfig.BucketExcludePattern, bucketExcludePattern, "BUCKET_EXCLUDE_PATTERN", ""),
-		DefaultScrapeConfig: ScrapeConfig{
-			MetricsPath:    "/minio/metrics/v3",
-			ScrapeInterval: "15s",
-			ScrapeTimeout:  "10s",
-			Scheme:         "http",
-		},
+	if profile != "" && !fileLoaded {
+		return Config{}, nil, fmt.Errorf("profile %s is selected but no config file is loaded", profile)
 	}
 
-	// Update scheme based on SSL configuration
-	if config.MinIOUseSSL {
-		config.DefaultScrapeConfig.Scheme = "https"
+	loader := &ConfigLoader{path: *configFile, required: fileLoaded, flags: flagValues(flags)}
+	config, err := buildConfig(fileConfig, loader.flags)
+	if err != nil {
+		return Config{}, nil, err
 	}
-
-	return config
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if level, err := logrus.ParseLevel(config.LogLevel); err == nil {
+		logrus.SetLevel(level)
+		logrus.Infof("Log level set to: %s", level.String())
 	}
-	return defaultValue
-}
 
-// getEnvAsBool gets an environment variable as a boolean or returns a default value
-func getEnvAsBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+	if *printConfig {
+		if err := printConfigSettings(os.Stdout, config); err != nil {
+			return Config{}, nil, err
 		}
+		os.Exit(0)
 	}
-	return defaultValue
-}
 
-// getEnvAsDuration gets an environment variable as a duration or returns a default value
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
-	}
-	return defaultValue
+	return config, loader, nil
 }
 
 // maskSensitive masks sensitive configuration values for logging
@@ -636,8 +865,40 @@ func main() {
 		FullTimestamp: true,
 	})
 
+	// Subcommands run once instead of serving; they take the same flags as the service
+	command := ""
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	commands := []string{"prometheus-config", "file-sd", "vmagent-config", "telegraf-config", "otelcol-config", "alloy-config", "check-config", "config-schema"}
+	if command != "" && !slices.Contains(commands, command) {
+		logrus.Fatalf("Unknown command %q (expected one of %s)", command, strings.Join(commands, ", "))
+	}
+
+	if command == "config-schema" {
+		if err := writeConfigSchema(os.Stdout); err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		return
+	}
+
 	// Load configuration
-	config := loadConfig()
+	config, loader, err := loadConfig(os.Args[1:])
+	if command == "check-config" {
+		if err == nil {
+			err = checkConfig(config)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration is invalid:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid")
+		return
+	}
+	if err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Log configuration
 	logrus.Infof("Configuration loaded:")
@@ -646,13 +907,35 @@ func main() {
 	logrus.Infof("  MinIO Secret Key: %s", maskSensitive(config.MinIOSecretKey))
 	logrus.Infof("  MinIO Use SSL: %t", config.MinIOUseSSL)
 	logrus.Infof("  Listen Address: %s", config.ListenAddr)
+	logrus.Infof("  Web Config File: %s", config.WebConfigFile)
+	logrus.Infof("  Shutdown Timeout: %v", config.ShutdownTimeout)
+	logrus.Infof("  Readiness Max Age: %v", config.ReadinessMaxAge)
 	logrus.Infof("  Scrape Interval: %v", config.ScrapeInterval)
+	logrus.Infof("  Scrape Timeout: %v", config.ScrapeTimeout)
 	logrus.Infof("  Metrics Path: %s", config.MetricsPath)
 	logrus.Infof("  Bucket Pattern: %s", config.BucketPattern)
 	logrus.Infof("  Bucket Exclude Pattern: %s", config.BucketExcludePattern)
+	logrus.Infof("  Cluster Name: %s", config.ClusterName)
+	logrus.Infof("  /sd Allowed Parameters: %v", config.SDAllowedParams)
+	logrus.Infof("  Shard Labels: %v", config.ShardLabels)
+	logrus.Infof("  file_sd: dir=%s format=%s", config.FileSDDir, config.FileSDFormat)
+	logrus.Infof("  Consul Listen Address: %s", config.ConsulListenAddr)
+	logrus.Infof("  DNS Listen Address: %s (domain %s)", config.DNSListenAddr, config.DNSDomain)
+	logrus.Infof("  Timeouts: ServerInfo=%v ListBuckets=%v Enrichment=%v", config.ServerInfoTimeout, config.ListBucketsTimeout, config.EnrichmentTimeout)
+	logrus.Infof("  Bucket Enrichment: %v (workers=%d, deadline=%v)", config.BucketEnrichment, config.EnrichmentWorkers, config.EnrichmentDeadline)
+	logrus.Infof("  Circuit Breaker: threshold=%d backoff=%v..%v", config.BreakerFailureThreshold, config.BackoffInitial, config.BackoffMax)
 
 	logrus.Infof("Starting MinIO Prometheus Service Discovery service...")
 
+	// Load TLS and basic auth settings from the web config file
+	tlsConfig, err := applyWebConfig(&config)
+	if err != nil {
+		logrus.Fatalf("Invalid web config: %v", err)
+	}
+	if err := validateConfig(config); err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Create MinIO client
 	logrus.Infof("Creating MinIO client for endpoint: %s", config.MinIOEndpoint)
 	minioClient, err := NewMinIOClient(config)
@@ -661,6 +944,63 @@ func main() {
 	}
 	logrus.Infof("MinIO client created successfully")
 
+	switch command {
+	case "prometheus-config":
+		data, err := minioClient.RenderPrometheusConfig(context.Background())
+		if err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	case "vmagent-config":
+		data, err := minioClient.RenderVMAgentConfig(context.Background())
+		if err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	case "otelcol-config":
+		data, err := minioClient.RenderOTelCollectorConfig(context.Background())
+		if err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	case "alloy-config":
+		data, err := minioClient.RenderAlloyConfig(context.Background())
+		if err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	case "telegraf-config":
+		snapshot, err := minioClient.Refresh(context.Background())
+		if err != nil {
+			logrus.Fatalf("Discovery failed: %v", err)
+		}
+		data, err := minioClient.RenderTelegrafConfig(context.Background(), snapshot)
+		if err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	case "file-sd":
+		if config.FileSDDir == "" {
+			logrus.Fatalf("file-sd requires -file-sd-dir")
+		}
+		snapshot, err := minioClient.Refresh(context.Background())
+		if err != nil {
+			logrus.Fatalf("Discovery failed, file_sd files left untouched: %v", err)
+		}
+		if err := minioClient.WriteFileSD(context.Background(), snapshot); err != nil {
+			logrus.Fatalf("%v", err)
+		}
+		return
+	}
+
+	// Reload the configuration on SIGHUP, config file changes and POST /-/reload
+	reloader := NewReloader(minioClient, loader)
+
 	// Create router
 	logrus.Infof("Setting up HTTP router and middleware")
 	router := mux.NewRouter()
@@ -674,91 +1014,135 @@ func main() {
 		})
 	})
 
+	// Add middleware for self-monitoring metrics
+	router.Use(minioClient.metrics.Middleware)
+
+	// Add authentication middleware; credentials and policies are replaced on reload
+	router.Use(minioClient.AuthMiddleware)
+	if config.Auth.Enabled() {
+		logrus.Infof("Authentication enabled for HTTP endpoints")
+	}
+
 	// Register routes
 	logrus.Infof("Registering HTTP routes:")
 	logrus.Infof("  GET /sd - Service discovery endpoint")
 	logrus.Infof("  GET /scrape_configs - Scrape configurations endpoint")
-	logrus.Infof("  GET /health - Health check endpoint")
-	logrus.Infof("  GET / - Documentation endpoint")
+	logrus.Infof("  GET /prometheus/scrape_configs.yaml - Prometheus scrape configs using HTTP SD")
+	logrus.Infof("  GET /vmagent/scrape_configs.yaml - vmagent scrape configs using HTTP SD")
+	logrus.Infof("  GET /telegraf/inputs.conf - Telegraf inputs.prometheus blocks")
+	logrus.Infof("  GET /otelcol/receivers.yaml - OpenTelemetry Collector prometheus receiver")
+	logrus.Infof("  GET /alloy/config.alloy - Grafana Alloy discovery and scrape components")
+	logrus.Infof("  GET /health - Detailed health diagnostic endpoint")
+	logrus.Infof("  GET /-/healthy - Liveness endpoint")
+	logrus.Infof("  GET /-/ready - Readiness endpoint")
+	logrus.Infof("  POST /-/reload - Reload the configuration")
+	logrus.Infof("  GET /metrics - Self-monitoring metrics endpoint")
+	logrus.Infof("  GET / - Web UI showing the current discovery state")
 	router.HandleFunc("/sd", minioClient.handleServiceDiscovery).Methods("GET")
 	router.HandleFunc("/scrape_configs", minioClient.handleScrapeConfigs).Methods("GET")
+	router.HandleFunc("/prometheus/scrape_configs.yaml", minioClient.handlePrometheusConfig).Methods("GET")
+	router.HandleFunc("/vmagent/scrape_configs.yaml", minioClient.handleVMAgentConfig).Methods("GET")
+	router.HandleFunc("/telegraf/inputs.conf", minioClient.handleTelegrafConfig).Methods("GET")
+	router.HandleFunc("/otelcol/receivers.yaml", minioClient.handleOTelCollectorConfig).Methods("GET")
+	router.HandleFunc("/alloy/config.alloy", minioClient.handleAlloyConfig).Methods("GET")
 	router.HandleFunc("/health", minioClient.handleHealth).Methods("GET")
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprintf(w, `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>MinIO Prometheus Service Discovery</title>
-    <style>
-        body { font-family: Arial, sans-serif; margin: 40px; }
-        .endpoint { background: #f5f5f5; padding: 10px; margin: 10px 0; border-radius: 5px; }
-        .method { color: #0066cc; font-weight: bold; }
-        .url { font-family: monospace; }
-        .required { color: #cc0000; font-weight: bold; }
-    </style>
-</head>
-<body>
-    <h1>MinIO Prometheus Service Discovery</h1>
-    <p>This service provides Prometheus HTTP service discovery for MinIO v3 metrics.</p>
-    
-    <h2>Available Endpoints:</h2>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> <span class="url">/sd?job=minio-server</span>
-        <p>Get service discovery targets for MinIO server metrics</p>
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> <span class="url">/sd?job=minio-buckets</span>
-        <p>Get service discovery targets for all MinIO bucket metrics (dynamically filtered)</p>
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> <span class="url">/scrape_configs</span>
-        <p>Get all available scrape configurations</p>
-    </div>
-    
-    <div class="endpoint">
-        <span class="method">GET</span> <span class="url">/health</span>
-        <p>Health check endpoint (returns JSON status)</p>
-    </div>
-    
-    <h2>Configuration:</h2>
-    <p>Set the following environment variables to configure the service:</p>
-    <ul>
-        <li><strong>MINIO_ENDPOINT</strong>: MinIO server endpoint (default: localhost:9000)</li>
-        <li><strong>MINIO_ACCESS_KEY</strong>: MinIO access key (default: minioadmin)</li>
-        <li><strong>MINIO_SECRET_KEY</strong>: MinIO secret key (default: minioadmin)</li>
-        <li><strong>MINIO_USE_SSL</strong>: Use SSL for MinIO connection (default: false)</li>
-        <li><strong>LISTEN_ADDR</strong>: Address to listen on (default: :8080)</li>
-        <li><strong>SCRAPE_INTERVAL</strong>: Scrape interval (default: 15s)</li>
-        <li><strong>BUCKET_PATTERN</strong>: Wildcard pattern for bucket inclusion (default: *)</li>
-        <li><strong>BUCKET_EXCLUDE_PATTERN</strong>: Wildcard pattern for bucket exclusion (default: empty)</li>
-    </ul>
-    
-    <h2>Features:</h2>
-    <ul>
-        <li>Dynamic bucket discovery with wildcard filtering</li>
-        <li>MinIO v3 metrics support (server and bucket metrics)</li>
-        <li>Prometheus HTTP Service Discovery compatible</li>
-        <li>Configurable bucket inclusion/exclusion patterns</li>
-    </ul>
-    
-    <h2>Getting Started:</h2>
-    <ol>
-        <li>Start the service: <code>go run main.go</code></li>
-        <li>Configure Prometheus to use the service discovery endpoints</li>
-    </ol>
-</body>
-</html>
-`)
-	}).Methods("GET")
+	router.HandleFunc("/-/healthy", minioClient.handleHealthy).Methods("GET", "HEAD")
+	router.HandleFunc("/-/ready", minioClient.handleReady).Methods("GET", "HEAD")
+	router.HandleFunc("/-/reload", reloader.handleReload).Methods("POST")
+	router.Handle("/metrics", minioClient.metrics.Handler()).Methods("GET")
+	router.HandleFunc("/", minioClient.handleUI).Methods("GET")
+	minioClient.RegisterAPIRoutes(router)
+
+	// Stop on SIGTERM (systemd, Kubernetes) or SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Keep the discovery snapshot warm in the background
+	refresherCtx, stopRefresher := context.WithCancel(context.Background())
+	defer stopRefresher()
+	go minioClient.RunRefresher(refresherCtx)
+	go reloader.Run(refresherCtx)
+	if config.FileSDDir != "" {
+		logrus.Infof("Writing %s file_sd files to %s every %v", config.FileSDFormat, config.FileSDDir, config.ScrapeInterval)
+		go minioClient.RunFileSDWriter(refresherCtx)
+	}
 
 	// Start server
-	logrus.Infof("Starting HTTP server on %s", config.ListenAddr)
-	logrus.Infof("Service is ready to accept requests")
-	if err := http.ListenAndServe(config.ListenAddr, router); err != nil {
+	server := &http.Server{
+		Addr:      config.ListenAddr,
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
+	serverErr := make(chan error, 3)
+	go func() {
+		if tlsConfig != nil {
+			logrus.Infof("Starting HTTPS server on %s", config.ListenAddr)
+			logrus.Infof("Service is ready to accept requests")
+			serverErr <- server.ListenAndServeTLS("", "")
+		} else {
+			logrus.Infof("Starting HTTP server on %s", config.ListenAddr)
+			logrus.Infof("Service is ready to accept requests")
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	// Start the emulated Consul catalog API, sharing TLS and authentication with the main listener
+	var consulServer *http.Server
+	if config.ConsulListenAddr != "" {
+		catalog := NewConsulCatalog(minioClient)
+		go catalog.Run(refresherCtx)
+
+		consulRouter := catalog.Router()
+		consulRouter.Use(minioClient.metrics.Middleware)
+		consulRouter.Use(minioClient.AuthMiddleware)
+		consulServer = &http.Server{
+			Addr:      config.ConsulListenAddr,
+			Handler:   consulRouter,
+			TLSConfig: tlsConfig,
+		}
+		go func() {
+			logrus.Infof("Starting Consul catalog API on %s", config.ConsulListenAddr)
+			if tlsConfig != nil {
+				serverErr <- consulServer.ListenAndServeTLS("", "")
+			} else {
+				serverErr <- consulServer.ListenAndServe()
+			}
+		}()
+	}
+
+	// Start the embedded DNS responder; it stops with the refresher
+	if config.DNSListenAddr != "" {
+		dnsServer := NewDNSServer(minioClient, config.DNSDomain)
+		go func() {
+			logrus.Infof("Starting DNS responder for %s on %s", config.DNSDomain, config.DNSListenAddr)
+			if err := dnsServer.ListenAndServe(refresherCtx, config.DNSListenAddr); err != nil {
+				serverErr <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
 		logrus.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+
+	// Drain in-flight requests; /-/ready reports not ready from now on
+	shutdownTimeout := minioClient.cfg().ShutdownTimeout
+	logrus.Infof("Shutdown signal received, draining requests for up to %v", shutdownTimeout)
+	minioClient.shuttingDown.Store(true)
+	stopRefresher()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if consulServer != nil {
+		if err := consulServer.Shutdown(shutdownCtx); err != nil {
+			logrus.Errorf("Graceful shutdown of the Consul catalog API did not complete: %v", err)
+		}
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logrus.Errorf("Graceful shutdown did not complete: %v", err)
+		return
 	}
+	logrus.Infof("Server stopped")
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -52,7 +54,10 @@ log_level: "info"`
 	}
 	defer os.Remove(testConfigPath) // Clean up
 
-	config := loadConfig()
+	config, _, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
 
 	// Test expected values
 	if config.MinIOEndpoint != "localhost:9000" {
@@ -68,69 +73,6 @@ log_level: "info"`
 	}
 }
 
-func TestGetEnv(t *testing.T) {
-	// Test default value
-	result := getEnv("NONEXISTENT_VAR", "default_value")
-	if result != "default_value" {
-		t.Errorf("Expected 'default_value', got '%s'", result)
-	}
-}
-
-func TestGetEnvAsBool(t *testing.T) {
-	tests := []struct {
-		name         string
-		envValue     string
-		defaultValue bool
-		expected     bool
-	}{
-		{"true value", "true", false, true},
-		{"false value", "false", true, false},
-		{"invalid value", "invalid", true, true},
-		{"empty value", "", false, false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Set environment variable temporarily
-			if tt.envValue != "" {
-				t.Setenv("TEST_BOOL_VAR", tt.envValue)
-			}
-
-			result := getEnvAsBool("TEST_BOOL_VAR", tt.defaultValue)
-			if result != tt.expected {
-				t.Errorf("Expected %v, got %v", tt.expected, result)
-			}
-		})
-	}
-}
-
-func TestGetEnvAsDuration(t *testing.T) {
-	tests := []struct {
-		name         string
-		envValue     string
-		defaultValue time.Duration
-		expected     time.Duration
-	}{
-		{"valid duration", "30s", 15 * time.Second, 30 * time.Second},
-		{"invalid duration", "invalid", 15 * time.Second, 15 * time.Second},
-		{"empty value", "", 15 * time.Second, 15 * time.Second},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Set environment variable temporarily
-			if tt.envValue != "" {
-				t.Setenv("TEST_DURATION_VAR", tt.envValue)
-			}
-
-			result := getEnvAsDuration("TEST_DURATION_VAR", tt.defaultValue)
-			if result != tt.expected {
-				t.Errorf("Expected %v, got %v", tt.expected, result)
-			}
-		})
-	}
-}
-
 func TestScrapeConfigValidation(t *testing.T) {
 	config := ScrapeConfig{
 		JobName:        "test-job",
@@ -205,23 +147,65 @@ func (m *MockMinIOClient) ListBuckets(ctx context.Context) ([]interface{}, error
 }
 
 func TestGenerateScrapeConfigs(t *testing.T) {
-	// This test would require mocking the MinIO client
-	// For now, we'll just test the structure
-	config := Config{
-		MinIOEndpoint: "localhost:9000",
-		DefaultScrapeConfig: ScrapeConfig{
-			MetricsPath:    "/minio/metrics/v3",
-			ScrapeInterval: "15s",
-			ScrapeTimeout:  "10s",
-			Scheme:         "http",
-		},
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ScrapeInterval = 30 * time.Second
+		c.Jobs = map[string]JobSettings{
+			"minio-buckets": {ScrapeInterval: 5 * time.Minute, ScrapeTimeout: time.Minute, MetricsPath: "/minio/metrics/v3/bucket/replication"},
+		}
+	})
+
+	configs, err := client.GenerateScrapeConfigs(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateScrapeConfigs failed: %v", err)
+	}
+	expected := []ScrapeConfig{
+		{JobName: "minio-server", MetricsPath: "/minio/metrics/v3", ScrapeInterval: "30s", ScrapeTimeout: "10s", Scheme: "http"},
+		{JobName: "minio-buckets", MetricsPath: "/minio/metrics/v3/bucket/replication", ScrapeInterval: "5m", ScrapeTimeout: "1m", Scheme: "http"},
+	}
+	if len(configs) != len(expected) {
+		t.Fatalf("Expected %d scrape configs, got %d", len(expected), len(configs))
+	}
+	for i, want := range expected {
+		got := configs[i]
+		if got.JobName != want.JobName || got.MetricsPath != want.MetricsPath || got.ScrapeInterval != want.ScrapeInterval ||
+			got.ScrapeTimeout != want.ScrapeTimeout || got.Scheme != want.Scheme {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+		labels := got.StaticConfigs[0].Labels
+		if labels["__scrape_interval__"] != want.ScrapeInterval || labels["__scrape_timeout__"] != want.ScrapeTimeout {
+			t.Errorf("Job %s: expected scrape labels %s/%s, got %v", want.JobName, want.ScrapeInterval, want.ScrapeTimeout, labels)
+		}
 	}
+}
 
-	if config.MinIOEndpoint != "localhost:9000" {
-		t.Errorf("Expected MinIO endpoint 'localhost:9000', got '%s'", config.MinIOEndpoint)
+func TestHandleReady(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ReadinessMaxAge = time.Minute
+	})
+
+	tests := []struct {
+		name         string
+		snapshot     *Snapshot
+		shuttingDown bool
+		expected     int
+	}{
+		{"no snapshot", nil, false, http.StatusServiceUnavailable},
+		{"no successful refresh", &Snapshot{RefreshedAt: time.Now()}, false, http.StatusServiceUnavailable},
+		{"fresh snapshot", &Snapshot{UpdatedAt: time.Now()}, false, http.StatusOK},
+		{"stale snapshot", &Snapshot{UpdatedAt: time.Now().Add(-2 * time.Minute)}, false, http.StatusServiceUnavailable},
+		{"shutting down", &Snapshot{UpdatedAt: time.Now()}, true, http.StatusServiceUnavailable},
 	}
 
-	if config.DefaultScrapeConfig.MetricsPath != "/minio/metrics/v3" {
-		t.Errorf("Expected metrics path '/minio/metrics/v3', got '%s'", config.DefaultScrapeConfig.MetricsPath)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.snapshot = tt.snapshot
+			client.shuttingDown.Store(tt.shuttingDown)
+
+			recorder := httptest.NewRecorder()
+			client.handleReady(recorder, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+			if recorder.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, recorder.Code)
+			}
+		})
 	}
 }
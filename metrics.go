@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Build information, overridable at build time with -ldflags "-X main.version=v1.2.3 -X main.revision=abc123"
+var (
+	version  = "dev"
+	revision = ""
+)
+
+// Metrics holds the self-monitoring metrics of the service discovery
+type Metrics struct {
+	registry *prometheus.Registry
+
+	callDuration    *prometheus.HistogramVec
+	callErrors      *prometheus.CounterVec
+	refreshDuration *prometheus.HistogramVec
+	refreshes       *prometheus.CounterVec
+	nodes           *prometheus.GaugeVec
+	buckets         *prometheus.GaugeVec
+	targets         *prometheus.GaugeVec
+	targetGroups    *prometheus.GaugeVec
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	reloadSuccess   prometheus.Gauge
+	reloadTimestamp prometheus.Gauge
+}
+
+// NewMetrics creates the service metrics on a dedicated registry.
+// The MinIO client is used for metrics computed at scrape time (snapshot age, breaker state).
+func NewMetrics(m *MinIOClient) *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		callDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "eos_sd_discovery_call_duration_seconds",
+			Help:    "Duration of MinIO calls made during discovery, by cluster and call type.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"cluster", "call"}),
+		callErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "eos_sd_discovery_call_errors_total",
+			Help: "Failed MinIO calls made during discovery, by cluster, call type and result (error, or rejected by the circuit breaker).",
+		}, []string{"cluster", "call", "result"}),
+		refreshDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "eos_sd_discovery_refresh_duration_seconds",
+			Help:    "Duration of full discovery refreshes, by cluster.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"cluster"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "eos_sd_discovery_refreshes_total",
+			Help: "Discovery refreshes, by cluster and result (success or error).",
+		}, []string{"cluster", "result"}),
+		nodes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eos_sd_discovered_nodes",
+			Help: "Number of MinIO nodes in the current discovery snapshot.",
+		}, []string{"cluster"}),
+		buckets: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eos_sd_discovered_buckets",
+			Help: "Number of buckets in the current discovery snapshot, before (stage=\"discovered\") and after (stage=\"filtered\") bucket filtering.",
+		}, []string{"cluster", "stage"}),
+		targets: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eos_sd_targets",
+			Help: "Number of targets emitted in the last service discovery response, by job.",
+		}, []string{"job"}),
+		targetGroups: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "eos_sd_target_groups",
+			Help: "Number of target groups emitted in the last service discovery response, by job.",
+		}, []string{"job"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "eos_sd_http_requests_total",
+			Help: "HTTP requests served, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "eos_sd_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		reloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "eos_sd_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful.",
+		}),
+		reloadTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "eos_sd_config_last_reload_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful configuration load or reload.",
+		}),
+	}
+	metrics.reloadSuccess.Set(1)
+	metrics.reloadTimestamp.SetToCurrentTime()
+
+	buildInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "eos_sd_build_info",
+		Help: "Build information of the service discovery, constant 1.",
+	}, []string{"version", "revision", "goversion"})
+	buildInfo.WithLabelValues(version, buildRevision(), runtime.Version()).Set(1)
+
+	cluster := prometheus.Labels{"cluster": m.cfg().ClusterName}
+	snapshotAge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "eos_sd_snapshot_age_seconds",
+		Help:        "Seconds since the discovery snapshot was last refreshed successfully. -1 if no refresh succeeded yet.",
+		ConstLabels: cluster,
+	}, func() float64 {
+		snapshot := m.currentSnapshot()
+		if snapshot == nil || snapshot.UpdatedAt.IsZero() {
+			return -1
+		}
+		return time.Since(snapshot.UpdatedAt).Seconds()
+	})
+	snapshotTimestamp := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "eos_sd_snapshot_timestamp_seconds",
+		Help:        "Unix timestamp of the last successful discovery refresh. 0 if no refresh succeeded yet.",
+		ConstLabels: cluster,
+	}, func() float64 {
+		snapshot := m.currentSnapshot()
+		if snapshot == nil || snapshot.UpdatedAt.IsZero() {
+			return 0
+		}
+		return float64(snapshot.UpdatedAt.UnixNano()) / 1e9
+	})
+
+	metrics.registry.MustRegister(
+		metrics.callDuration,
+		metrics.callErrors,
+		metrics.refreshDuration,
+		metrics.refreshes,
+		metrics.nodes,
+		metrics.buckets,
+		metrics.targets,
+		metrics.targetGroups,
+		metrics.httpRequests,
+		metrics.httpDuration,
+		metrics.reloadSuccess,
+		metrics.reloadTimestamp,
+		buildInfo,
+		snapshotAge,
+		snapshotTimestamp,
+		&breakerCollector{cluster: m.cfg().ClusterName, breaker: m.breaker},
+		&inventoryCollector{client: m},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return metrics
+}
+
+// Handler returns the HTTP handler serving the metrics
+func (mt *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(mt.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveCall records the outcome of a single MinIO call. Calls rejected by the circuit
+// breaker never reached MinIO, so they are counted but not timed.
+func (mt *Metrics) ObserveCall(cluster, call string, duration time.Duration, err error) {
+	if errors.Is(err, ErrCircuitOpen) {
+		mt.callErrors.WithLabelValues(cluster, call, "rejected").Inc()
+		return
+	}
+	mt.callDuration.WithLabelValues(cluster, call).Observe(duration.Seconds())
+	if err != nil {
+		mt.callErrors.WithLabelValues(cluster, call, "error").Inc()
+	}
+}
+
+// ObserveRefresh records the outcome of a full discovery refresh
+func (mt *Metrics) ObserveRefresh(snapshot *Snapshot, duration time.Duration, err error) {
+	mt.refreshDuration.WithLabelValues(snapshot.Cluster).Observe(duration.Seconds())
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	mt.refreshes.WithLabelValues(snapshot.Cluster, result).Inc()
+	mt.nodes.WithLabelValues(snapshot.Cluster).Set(float64(len(snapshot.Nodes)))
+	mt.buckets.WithLabelValues(snapshot.Cluster, "discovered").Set(float64(len(snapshot.Buckets)))
+}
+
+// ObserveReload records the outcome of a configuration reload
+func (mt *Metrics) ObserveReload(status ReloadStatus) {
+	if !status.Success {
+		mt.reloadSuccess.Set(0)
+		return
+	}
+	mt.reloadSuccess.Set(1)
+	mt.reloadTimestamp.Set(float64(status.Time.UnixNano()) / 1e9)
+}
+
+// ObserveFilteredBuckets records the number of buckets left after filtering
+func (mt *Metrics) ObserveFilteredBuckets(cluster string, count int) {
+	mt.buckets.WithLabelValues(cluster, "filtered").Set(float64(count))
+}
+
+// ObserveTargets records the size of a service discovery response
+func (mt *Metrics) ObserveTargets(job string, response []ServiceDiscoveryResponse) {
+	targets := 0
+	for _, group := range response {
+		targets += len(group.Targets)
+	}
+	mt.targets.WithLabelValues(job).Set(float64(targets))
+	mt.targetGroups.WithLabelValues(job).Set(float64(len(response)))
+}
+
+// Middleware instruments HTTP requests by route template, method and status code
+func (mt *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		mt.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		mt.httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before passing it on
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// breakerCollector exports the circuit breaker state at scrape time
+type breakerCollector struct {
+	cluster string
+	breaker *CircuitBreaker
+}
+
+var (
+	breakerStateDesc = prometheus.NewDesc("eos_sd_circuit_breaker_state",
+		"Current circuit breaker state per cluster; 1 for the active state.",
+		[]string{"cluster", "state"}, nil)
+	breakerFailuresDesc = prometheus.NewDesc("eos_sd_circuit_breaker_consecutive_failures",
+		"Consecutive failed MinIO calls per cluster.",
+		[]string{"cluster"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (c *breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+	ch <- breakerFailuresDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	status := c.breaker.Status()
+	for _, state := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+		value := 0.0
+		if state.String() == status.State {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, value, c.cluster, state.String())
+	}
+	ch <- prometheus.MustNewConstMetric(breakerFailuresDesc, prometheus.GaugeValue, float64(status.ConsecutiveFailures), c.cluster)
+}
+
+// inventoryCollector exports the discovery snapshot as info-style series, so MinIO metrics
+// can be joined against bucket and node metadata in PromQL with group_left
+type inventoryCollector struct {
+	client *MinIOClient
+}
+
+var (
+	bucketInfoDesc = prometheus.NewDesc("eos_sd_bucket_info",
+		"Metadata of discovered buckets, constant 1.",
+		[]string{"cluster", "bucket", "creation", "owner", "versioning", "included"}, nil)
+	nodeInfoDesc = prometheus.NewDesc("eos_sd_node_info",
+		"Metadata of discovered MinIO nodes, constant 1.",
+		[]string{"cluster", "node", "pool", "state", "version"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (c *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bucketInfoDesc
+	ch <- nodeInfoDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.client.currentSnapshot()
+	if snapshot == nil {
+		return
+	}
+
+	included := make(map[string]bool)
+	for _, bucket := range c.client.bucketFilter().Apply(snapshot.Buckets) {
+		included[bucket.Name] = true
+	}
+
+	for _, bucket := range snapshot.Buckets {
+		metadata := snapshot.Metadata[bucket.Name]
+		ch <- prometheus.MustNewConstMetric(bucketInfoDesc, prometheus.GaugeValue, 1,
+			snapshot.Cluster,
+			bucket.Name,
+			bucket.CreationDate.Format(time.RFC3339),
+			metadata.Owner,
+			metadata.Versioning,
+			strconv.FormatBool(included[bucket.Name]),
+		)
+	}
+
+	for _, node := range snapshot.Nodes {
+		pools := make([]string, 0, len(node.Pools))
+		for _, pool := range node.Pools {
+			pools = append(pools, strconv.Itoa(pool))
+		}
+		ch <- prometheus.MustNewConstMetric(nodeInfoDesc, prometheus.GaugeValue, 1,
+			snapshot.Cluster,
+			node.Endpoint,
+			strings.Join(pools, ","),
+			node.State,
+			node.Version,
+		)
+	}
+}
+
+// buildRevision returns the revision set at build time, falling back to the VCS information embedded by the Go toolchain
+func buildRevision() string {
+	if revision != "" {
+		return revision
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
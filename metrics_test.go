@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testConfig returns a configuration that passes validation and never reaches a server,
+// with the overrides applied in order
+func testConfig(overrides ...func(*Config)) Config {
+	config := Config{
+		MinIOEndpoint:           "localhost:9000",
+		MinIOAccessKey:          "test",
+		MinIOSecretKey:          "test",
+		ListenAddr:              ":8080",
+		ClusterName:             "test",
+		BucketPattern:           "*",
+		ScrapeInterval:          time.Minute,
+		ScrapeTimeout:           10 * time.Second,
+		MetricsPath:             "/minio/metrics/v3",
+		FileSDFormat:            FileSDFormatJSON,
+		ShardLabels:             []string{"__address__"},
+		BreakerFailureThreshold: 3,
+	}
+	for _, override := range overrides {
+		override(&config)
+	}
+	return config
+}
+
+// newTestMinIOClient creates a MinIO client from testConfig with the given overrides
+func newTestMinIOClient(t *testing.T, overrides ...func(*Config)) *MinIOClient {
+	t.Helper()
+	client, err := NewMinIOClient(testConfig(overrides...))
+	if err != nil {
+		t.Fatalf("Failed to create MinIO client: %v", err)
+	}
+	return client
+}
+
+func TestMetricsObserveTargets(t *testing.T) {
+	client := newTestMinIOClient(t)
+
+	client.metrics.ObserveTargets("minio-buckets", []ServiceDiscoveryResponse{
+		{Targets: []string{"node1:9000", "node2:9000"}},
+		{Targets: []string{"node1:9000", "node2:9000"}},
+	})
+
+	if got := testutil.ToFloat64(client.metrics.targets.WithLabelValues("minio-buckets")); got != 4 {
+		t.Errorf("Expected 4 targets, got %v", got)
+	}
+	if got := testutil.ToFloat64(client.metrics.targetGroups.WithLabelValues("minio-buckets")); got != 2 {
+		t.Errorf("Expected 2 target groups, got %v", got)
+	}
+}
+
+func TestMetricsObserveCall(t *testing.T) {
+	client := newTestMinIOClient(t)
+
+	// Rejected calls never reached MinIO, so they are counted but not timed
+	client.metrics.ObserveCall("test", "ListBuckets", 0, ErrCircuitOpen)
+	if got := testutil.ToFloat64(client.metrics.callErrors.WithLabelValues("test", "ListBuckets", "rejected")); got != 1 {
+		t.Errorf("Expected 1 rejected call, got %v", got)
+	}
+	if got := testutil.CollectAndCount(client.metrics.callDuration); got != 0 {
+		t.Errorf("Expected no call duration for a rejected call, got %d series", got)
+	}
+
+	client.metrics.ObserveCall("test", "ListBuckets", time.Second, errors.New("connection refused"))
+	if got := testutil.ToFloat64(client.metrics.callErrors.WithLabelValues("test", "ListBuckets", "error")); got != 1 {
+		t.Errorf("Expected 1 failed call, got %v", got)
+	}
+	if got := testutil.CollectAndCount(client.metrics.callDuration); got != 1 {
+		t.Errorf("Expected the failed call to be timed, got %d series", got)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	client := newTestMinIOClient(t)
+
+	router := mux.NewRouter()
+	router.Use(client.metrics.Middleware)
+	router.HandleFunc("/sd", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "job parameter is required", http.StatusBadRequest)
+	})
+	router.Handle("/metrics", client.metrics.Handler())
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	if _, err := http.Get(server.URL + "/sd"); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, expected := range []string{
+		`eos_sd_http_requests_total{code="400",method="GET",route="/sd"} 1`,
+		`eos_sd_snapshot_age_seconds{cluster="test"} -1`,
+		`eos_sd_circuit_breaker_state{cluster="test",state="closed"} 1`,
+		`eos_sd_build_info{`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected metrics output to contain %q", expected)
+		}
+	}
+}
+
+func TestInventoryInfoMetrics(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.BucketExcludePattern = "tmp-*"
+	})
+	client.snapshot = &Snapshot{
+		Cluster: "test",
+		Nodes: []NodeInfo{
+			{Endpoint: "node1:9000", State: "online", Pools: []int{0}, Version: "2024-01-01T00-00-00Z"},
+		},
+		Buckets: []minio.BucketInfo{
+			{Name: "payments", CreationDate: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{Name: "tmp-scratch", CreationDate: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		Metadata: map[string]BucketMetadata{
+			"payments": {Versioning: "Enabled", Owner: "team-payments"},
+		},
+	}
+
+	expected := `
+# HELP eos_sd_bucket_info Metadata of discovered buckets, constant 1.
+# TYPE eos_sd_bucket_info gauge
+eos_sd_bucket_info{bucket="payments",cluster="test",creation="2024-01-02T03:04:05Z",included="true",owner="team-payments",versioning="Enabled"} 1
+eos_sd_bucket_info{bucket="tmp-scratch",cluster="test",creation="2024-01-02T03:04:05Z",included="false",owner="",versioning=""} 1
+# HELP eos_sd_node_info Metadata of discovered MinIO nodes, constant 1.
+# TYPE eos_sd_node_info gauge
+eos_sd_node_info{cluster="test",node="node1:9000",pool="0",state="online",version="2024-01-01T00-00-00Z"} 1
+`
+	if err := testutil.CollectAndCompare(&inventoryCollector{client: client}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTargetGaugesOnlyReflectServiceDiscovery(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.BucketPattern = "prod-*"
+		c.FileSDDir = t.TempDir()
+	})
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "online"}},
+		Buckets:     []minio.BucketInfo{{Name: "prod-payments"}, {Name: "dev-payments"}},
+	}
+	client.handleServiceDiscovery(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sd?job=minio-buckets", nil))
+
+	// Other consumers of the target groups and metric scrapes leave the gauges alone
+	client.metrics.targetGroups.WithLabelValues("minio-buckets").Set(7)
+	client.metrics.buckets.WithLabelValues("test", "filtered").Set(7)
+	if err := client.WriteFileSD(context.Background(), client.snapshot); err != nil {
+		t.Fatalf("WriteFileSD failed: %v", err)
+	}
+	if _, err := NewConsulCatalog(client).consulServices(context.Background(), client.snapshot); err != nil {
+		t.Fatalf("Failed to build the Consul catalog: %v", err)
+	}
+	testutil.CollectAndCount(&inventoryCollector{client: client})
+
+	if got := testutil.ToFloat64(client.metrics.targetGroups.WithLabelValues("minio-buckets")); got != 7 {
+		t.Errorf("Expected eos_sd_target_groups to be left alone, got %v", got)
+	}
+	if got := testutil.ToFloat64(client.metrics.buckets.WithLabelValues("test", "filtered")); got != 7 {
+		t.Errorf("Expected the filtered bucket count to be left alone, got %v", got)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the generated document follows
+const openAPIVersion = "3.0.3"
+
+// schemaGenerator derives OpenAPI schemas from Go types through their JSON encoding. Named
+// struct types are emitted once under components/schemas and referenced from elsewhere.
+type schemaGenerator struct {
+	components map[string]any
+}
+
+// jsonField returns the JSON name of a struct field, whether it may be left out of the
+// encoding, and whether it is encoded at all
+func jsonField(field reflect.StructField) (name string, optional, ok bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "omitzero" {
+			optional = true
+		}
+	}
+	return name, optional, true
+}
+
+// schema returns the schema of the JSON encoding of values of type t
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// Nil slices are encoded as null
+		return map[string]any{"type": "array", "items": g.schema(t.Elem()), "nullable": true}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem()), "nullable": true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			g.components[t.Name()] = map[string]any{} // Placeholder for recursive types
+			g.components[t.Name()] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the object schema of a struct type, flattening embedded structs
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				addFields(field.Type)
+				continue
+			}
+			name, optional, ok := jsonField(field)
+			if !ok {
+				continue
+			}
+			properties[name] = g.schema(field.Type)
+			if !optional {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonContent returns an OpenAPI content map with a single JSON media type
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// GenerateOpenAPI builds the OpenAPI 3 document describing the given API routes
+func GenerateOpenAPI(routes []APIRoute) map[string]any {
+	generator := &schemaGenerator{components: make(map[string]any)}
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     jsonContent(generator.schema(reflect.TypeFor[APIErrorResponse]())),
+	}
+
+	paths := make(map[string]any)
+	for _, route := range routes {
+		var parameters []any
+		for _, param := range route.Params {
+			paramType := param.Type
+			if paramType == "" {
+				paramType = "string"
+			}
+			parameters = append(parameters, map[string]any{
+				"name":        param.Name,
+				"in":          param.In,
+				"description": param.Description,
+				"required":    param.Required,
+				"schema":      map[string]any{"type": paramType},
+			})
+		}
+
+		response := map[string]any{
+			"description": "OK",
+			"content":     jsonContent(generator.schema(reflect.TypeOf(route.Response))),
+		}
+		if route.Paginated {
+			parameters = append(parameters,
+				map[string]any{
+					"name": "limit", "in": "query", "required": false,
+					"description": "Maximum number of items to return",
+					"schema":      map[string]any{"type": "integer", "minimum": 1, "maximum": maxPageSize},
+				},
+				map[string]any{
+					"name": "offset", "in": "query", "required": false,
+					"description": "Number of items to skip",
+					"schema":      map[string]any{"type": "integer", "minimum": 0},
+				},
+			)
+			response["headers"] = map[string]any{
+				"X-Total-Count": map[string]any{"description": "Total number of items", "schema": map[string]any{"type": "integer"}},
+				"Link":          map[string]any{"description": `Link to the next page with rel="next", if any`, "schema": map[string]any{"type": "string"}},
+			}
+		}
+		if route.CSV {
+			parameters = append(parameters, map[string]any{
+				"name": "format", "in": "query", "required": false,
+				"description": "Response format; CSV can also be requested with Accept: text/csv",
+				"schema":      map[string]any{"type": "string", "enum": []any{"json", "csv"}},
+			})
+			response["content"].(map[string]any)["text/csv"] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+
+		operation := map[string]any{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"responses": map[string]any{
+				strconv.Itoa(http.StatusOK): response,
+				"default":                   errorResponse,
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Request != nil {
+			schema := generator.schema(reflect.TypeOf(route.Request))
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json":   map[string]any{"schema": schema},
+					"application/yaml":   map[string]any{"schema": schema},
+					"application/x-yaml": map[string]any{"schema": schema},
+				},
+			}
+		}
+
+		item, ok := paths[route.Path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "MinIO Prometheus Service Discovery API",
+			"description": "Inventory, discovery state and configuration of the service discovery. Errors use the error envelope.",
+			"version":     version,
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": generator.components},
+	}
+}
+
+// operationID derives a unique operation ID from the method and path of a route,
+// e.g. getJobsByJob for GET /api/v1/jobs/{job}
+func operationID(route APIRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, segment := range strings.Split(strings.TrimPrefix(route.Path, APIPrefix+"/"), "/") {
+		if param, ok := strings.CutPrefix(segment, "{"); ok {
+			segment = "By" + strings.TrimSuffix(param, "}")
+		}
+		segment = strings.ReplaceAll(segment, ".", "_")
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// maxPreviewBodySize limits the size of a candidate config fragment
+const maxPreviewBodySize = 1 << 20
+
+// ConfigFragment is a candidate change to the settings that affect generated targets.
+// Fields left out keep their current value; job overrides are merged setting by setting.
+type ConfigFragment struct {
+	BucketPattern        *string              `yaml:"bucket_pattern" json:"bucket_pattern,omitempty"`
+	BucketExcludePattern *string              `yaml:"bucket_exclude_pattern" json:"bucket_exclude_pattern,omitempty"`
+	Jobs                 map[string]JobConfig `yaml:"jobs" json:"jobs,omitempty"`
+}
+
+// LabelChange describes a label whose value differs between the served and candidate target group
+type LabelChange struct {
+	Label  string `json:"label"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// TargetGroupChange describes a target group present in both the served and candidate output but with different content
+type TargetGroupChange struct {
+	Key           string        `json:"key"`
+	LabelChanges  []LabelChange `json:"label_changes,omitempty"`
+	TargetsBefore []string      `json:"targets_before,omitempty"`
+	TargetsAfter  []string      `json:"targets_after,omitempty"`
+}
+
+// JobDiff is the difference between the served and candidate target groups of a job
+type JobDiff struct {
+	Added     []ServiceDiscoveryResponse `json:"added"`
+	Removed   []ServiceDiscoveryResponse `json:"removed"`
+	Changed   []TargetGroupChange        `json:"changed"`
+	Unchanged int                        `json:"unchanged"`
+}
+
+// PreviewResponse is the response of the /api/v1/preview endpoint
+type PreviewResponse struct {
+	Candidate ConfigFragment     `json:"candidate"`
+	Jobs      map[string]JobDiff `json:"jobs"`
+}
+
+// parseConfigFragment decodes a YAML or JSON config fragment, rejecting unknown keys
+func parseConfigFragment(data []byte) (ConfigFragment, error) {
+	var fragment ConfigFragment
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fragment); err != nil && !errors.Is(err, io.EOF) {
+		return fragment, err
+	}
+	return fragment, nil
+}
+
+// apply returns the configuration resulting from applying the fragment on top of the
+// current one. The result is checked like a reloaded configuration, so a candidate that
+// could not be put into effect is rejected instead of previewed.
+func (f ConfigFragment) apply(current Config) (Config, error) {
+	config := current
+	if f.BucketPattern != nil {
+		config.BucketPattern = *f.BucketPattern
+	}
+	if f.BucketExcludePattern != nil {
+		config.BucketExcludePattern = *f.BucketExcludePattern
+	}
+	if len(f.Jobs) > 0 {
+		jobs, err := parseJobConfigs(f.Jobs, "preview")
+		if err != nil {
+			return Config{}, err
+		}
+		config.Jobs = mergeJobSettings(current.Jobs, jobs)
+	}
+	if err := validateConfig(config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// targetGroupKey identifies a target group across two evaluations of the same job
+func targetGroupKey(group ServiceDiscoveryResponse) string {
+	if bucket, ok := group.Labels["sd_bucket"]; ok {
+		return bucket
+	}
+	return group.Labels["__metrics_path__"]
+}
+
+// diffTargetGroups compares the served and candidate target groups of a job
+func diffTargetGroups(before, after []ServiceDiscoveryResponse) JobDiff {
+	diff := JobDiff{
+		Added:   []ServiceDiscoveryResponse{},
+		Removed: []ServiceDiscoveryResponse{},
+		Changed: []TargetGroupChange{},
+	}
+
+	served := make(map[string]ServiceDiscoveryResponse, len(before))
+	for _, group := range before {
+		served[targetGroupKey(group)] = group
+	}
+
+	seen := make(map[string]bool, len(after))
+	for _, group := range after {
+		key := targetGroupKey(group)
+		seen[key] = true
+
+		old, ok := served[key]
+		if !ok {
+			diff.Added = append(diff.Added, group)
+			continue
+		}
+
+		change := TargetGroupChange{Key: key}
+		union := make(map[string]string, len(old.Labels))
+		maps.Copy(union, old.Labels)
+		maps.Copy(union, group.Labels)
+		for _, name := range slices.Sorted(maps.Keys(union)) {
+			if old.Labels[name] != group.Labels[name] {
+				change.LabelChanges = append(change.LabelChanges, LabelChange{Label: name, Before: old.Labels[name], After: group.Labels[name]})
+			}
+		}
+		if !slices.Equal(old.Targets, group.Targets) {
+			change.TargetsBefore = old.Targets
+			change.TargetsAfter = group.Targets
+		}
+
+		if len(change.LabelChanges) == 0 && change.TargetsAfter == nil {
+			diff.Unchanged++
+		} else {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+
+	for _, group := range before {
+		if !seen[targetGroupKey(group)] {
+			diff.Removed = append(diff.Removed, group)
+		}
+	}
+
+	return diff
+}
+
+// handlePreview handles POST /api/v1/preview. It evaluates a candidate config fragment
+// against the current snapshot without applying it and returns, per job, the difference
+// between the candidate target groups and those served now.
+func (m *MinIOClient) handlePreview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPreviewBodySize))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "failed to read request body: %v", err)
+		return
+	}
+
+	fragment, err := parseConfigFragment(data)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid config fragment: %v", err)
+		return
+	}
+
+	configs, err := m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to generate scrape configs: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "failed to generate scrape configs")
+		return
+	}
+
+	current := *m.cfg()
+	candidate, err := fragment.apply(current)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid config fragment: %v", err)
+		return
+	}
+
+	snapshot := m.Discover(ctx)
+	response := PreviewResponse{
+		Candidate: ConfigFragment{BucketPattern: &candidate.BucketPattern, BucketExcludePattern: &candidate.BucketExcludePattern, Jobs: fragment.Jobs},
+		Jobs:      make(map[string]JobDiff, len(configs)),
+	}
+	for _, config := range configs {
+		before := buildTargetGroupsFor(current, config, snapshot, current.filter())
+		after := buildTargetGroupsFor(candidate, config, snapshot, candidate.filter())
+		response.Jobs[config.JobName] = diffTargetGroups(before, after)
+	}
+
+	logrus.Infof("Config preview from %s: bucket_pattern=%q bucket_exclude_pattern=%q jobs=%v", r.RemoteAddr, candidate.BucketPattern, candidate.BucketExcludePattern, slices.Sorted(maps.Keys(fragment.Jobs)))
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestParseConfigFragmentRejectsUnknownKeys(t *testing.T) {
+	if _, err := parseConfigFragment([]byte(`bucket_exclude_patern: "*tmp*"`)); err == nil {
+		t.Error("Expected error for unknown key, got nil")
+	}
+
+	fragment, err := parseConfigFragment([]byte(`{"bucket_pattern": "prod-*"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fragment.BucketPattern == nil || *fragment.BucketPattern != "prod-*" || fragment.BucketExcludePattern != nil {
+		t.Errorf("Unexpected fragment: %+v", fragment)
+	}
+}
+
+func TestHandlePreview(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.BucketExcludePattern = "*tmp*"
+	})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "online"}},
+		Buckets: []minio.BucketInfo{
+			{Name: "prod-payments", CreationDate: created},
+			{Name: "prod-tmp", CreationDate: created},
+			{Name: "dev-payments", CreationDate: created},
+		},
+	}
+
+	body := strings.NewReader("bucket_pattern: \"prod-*\"\nbucket_exclude_pattern: \"\"\n")
+	recorder := httptest.NewRecorder()
+	client.handlePreview(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/preview", body))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response PreviewResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	buckets := response.Jobs["minio-buckets"]
+	if len(buckets.Added) != 1 || buckets.Added[0].Labels["sd_bucket"] != "prod-tmp" {
+		t.Errorf("Expected prod-tmp to be added, got %+v", buckets.Added)
+	}
+	if len(buckets.Removed) != 1 || buckets.Removed[0].Labels["sd_bucket"] != "dev-payments" {
+		t.Errorf("Expected dev-payments to be removed, got %+v", buckets.Removed)
+	}
+	if buckets.Unchanged != 1 {
+		t.Errorf("Expected 1 unchanged target group, got %d", buckets.Unchanged)
+	}
+	if server := response.Jobs["minio-server"]; server.Unchanged != 1 || len(server.Added)+len(server.Removed)+len(server.Changed) != 0 {
+		t.Errorf("Expected minio-server to be unchanged, got %+v", server)
+	}
+}
+
+func TestHandlePreviewJobsAndValidation(t *testing.T) {
+	client := newTestMinIOClient(t)
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "online"}},
+		Buckets:     []minio.BucketInfo{{Name: "prod-payments"}},
+	}
+	preview := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		client.handlePreview(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/preview", strings.NewReader(body)))
+		return recorder
+	}
+
+	recorder := preview("jobs:\n  minio-server:\n    scrape_interval: 30s\n")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response PreviewResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	server := response.Jobs["minio-server"]
+	if len(server.Changed) != 1 || len(server.Changed[0].LabelChanges) != 1 {
+		t.Fatalf("Expected one label change for minio-server, got %+v", server)
+	}
+	if change := server.Changed[0].LabelChanges[0]; change.Label != "__scrape_interval__" || change.Before != "1m" || change.After != "30s" {
+		t.Errorf("Expected __scrape_interval__ to change from 1m to 30s, got %+v", change)
+	}
+	if buckets := response.Jobs["minio-buckets"]; buckets.Unchanged != 1 {
+		t.Errorf("Expected minio-buckets to be unchanged, got %+v", buckets)
+	}
+
+	for _, tt := range []struct {
+		name, body, message string
+	}{
+		{"invalid pattern", `bucket_pattern: "prod-(*"`, `bucket_pattern: invalid pattern "prod-(*"`},
+		{"unknown job", "jobs:\n  minio-nodes:\n    scrape_interval: 30s\n", "jobs.minio-nodes: unknown job"},
+		{"invalid duration", "jobs:\n  minio-server:\n    scrape_interval: soon\n", `jobs.minio-server.scrape_interval (from preview): invalid duration "soon"`},
+		{"timeout above interval", "jobs:\n  minio-server:\n    scrape_timeout: 5m\n", "jobs.minio-server.scrape_timeout: must not be longer than the scrape interval"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := preview(tt.body)
+			var response APIErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if recorder.Code != http.StatusBadRequest || !strings.Contains(response.Error.Message, tt.message) {
+				t.Errorf("Expected status 400 with %q, got %d: %s", tt.message, recorder.Code, response.Error.Message)
+			}
+		})
+	}
+}
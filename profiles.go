@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileEnv is the environment variable selecting a profile. It is prefixed, unlike the
+// other settings, because a plain PROFILE is often set in shells and CI images.
+const ProfileEnv = "EOS_SD_PROFILE"
+
+// selectedProfile returns the profile chosen on the command line or in the environment.
+// Without one, the profile key of the config file applies.
+func selectedProfile(flags map[string]string) string {
+	if profile, ok := flags["profile"]; ok {
+		return profile
+	}
+	return os.Getenv(ProfileEnv)
+}
+
+// mappingIndex returns the index of a key in the content of a YAML mapping, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of a key of a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
+
+// mergeNodes returns base with the keys of override applied. Mappings present in both are
+// merged recursively; any other value of override, including lists, replaces the base one.
+// Neither node is modified.
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+	merged := *base
+	merged.Content = slices.Clone(base.Content)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		if index := mappingIndex(&merged, key.Value); index >= 0 {
+			merged.Content[index+1] = mergeNodes(merged.Content[index+1], value)
+		} else {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+	return &merged
+}
+
+// applyProfile merges a profile of a parsed config file into the base settings at its top
+// level, and returns the merged settings with the profile name and the keys the profile
+// sets. An empty profile selects the one named by the profile key of the file, if any.
+// Every profile is checked, so a broken one is reported before it is deployed.
+func applyProfile(root *yaml.Node, profile string) (*yaml.Node, string, []string, error) {
+	if root.Kind != yaml.MappingNode {
+		return root, "", nil, nil
+	}
+
+	base := *root
+	base.Content = nil
+	var profiles *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "profiles" {
+			profiles = root.Content[i+1]
+			continue
+		}
+		base.Content = append(base.Content, root.Content[i], root.Content[i+1])
+	}
+
+	var names []string
+	var errs []error
+	if profiles != nil && profiles.Kind != yaml.MappingNode && profiles.Tag != "!!null" {
+		return nil, "", nil, fmt.Errorf("line %d: profiles must map profile names to settings", profiles.Line)
+	}
+	if profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			name, settings := profiles.Content[i], profiles.Content[i+1]
+			names = append(names, name.Value)
+			if settings.Kind != yaml.MappingNode && settings.Tag != "!!null" {
+				errs = append(errs, fmt.Errorf("line %d: profile %s must be a mapping of settings", settings.Line, name.Value))
+				continue
+			}
+			for _, key := range []string{"profiles", "profile"} {
+				if value := mappingValue(settings, key); value != nil {
+					errs = append(errs, fmt.Errorf("line %d: profile %s must not set %s", value.Line, name.Value, key))
+				}
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, "", nil, err
+	}
+
+	if profile == "" {
+		if value := mappingValue(&base, "profile"); value != nil && value.Kind == yaml.ScalarNode {
+			profile = value.Value
+		}
+	}
+	if profile == "" {
+		return &base, "", nil, nil
+	}
+	if len(names) == 0 {
+		return nil, "", nil, fmt.Errorf("profile %s is selected but the config file defines no profiles", profile)
+	}
+	settings := mappingValue(profiles, profile)
+	if settings == nil {
+		slices.Sort(names)
+		return nil, "", nil, fmt.Errorf("unknown profile %s, the config file defines %s", profile, strings.Join(names, ", "))
+	}
+
+	if settings.Kind != yaml.MappingNode {
+		return &base, profile, nil, nil // Empty profile
+	}
+	var keys []string
+	for i := 0; i+1 < len(settings.Content); i += 2 {
+		keys = append(keys, settings.Content[i].Value)
+	}
+	return mergeNodes(&base, settings), profile, keys, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const profilesConfig = `minio_endpoint: "minio:9000"
+bucket_pattern: "*"
+shard_labels: [sd_bucket, __address__]
+profile: dev
+jobs:
+  minio-buckets:
+    scrape_interval: 1m
+profiles:
+  dev:
+    bucket_pattern: "dev-*"
+  prod:
+    minio_endpoint: "minio-prod.example.com:9000"
+    minio_secret_key: "${PROD_SECRET_KEY}"
+    bucket_pattern: "prod-*"
+    shard_labels: [sd_bucket]
+    jobs:
+      minio-buckets:
+        scrape_timeout: 30s
+  empty:
+`
+
+// writeProfilesConfig writes the profiles test config file and returns its path
+func writeProfilesConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(profilesConfig), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	path := writeProfilesConfig(t)
+
+	t.Run("profile of the file", func(t *testing.T) {
+		// Variables of other profiles need not be set
+		config, err := (&ConfigLoader{path: path}).Load()
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if config.Profile != "dev" || config.Sources["profile"] != SourceFile {
+			t.Errorf("Expected profile dev from the file, got %q from %s", config.Profile, config.Sources["profile"])
+		}
+		if config.BucketPattern != "dev-*" || config.Sources["bucket_pattern"] != SourceProfile {
+			t.Errorf("Expected bucket pattern dev-* from the profile, got %q from %s", config.BucketPattern, config.Sources["bucket_pattern"])
+		}
+		if config.MinIOEndpoint != "minio:9000" || config.Sources["minio_endpoint"] != SourceFile {
+			t.Errorf("Expected the base endpoint, got %q from %s", config.MinIOEndpoint, config.Sources["minio_endpoint"])
+		}
+	})
+
+	t.Run("selected profile", func(t *testing.T) {
+		t.Setenv("PROD_SECRET_KEY", "prod-secret")
+		config, err := (&ConfigLoader{path: path, flags: map[string]string{"profile": "prod"}}).Load()
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if config.Profile != "prod" || config.Sources["profile"] != SourceFlag {
+			t.Errorf("Expected profile prod from the flag, got %q from %s", config.Profile, config.Sources["profile"])
+		}
+		if config.MinIOEndpoint != "minio-prod.example.com:9000" || config.MinIOSecretKey != "prod-secret" || config.BucketPattern != "prod-*" {
+			t.Errorf("Expected the prod settings, got %s %s %s", config.MinIOEndpoint, config.MinIOSecretKey, config.BucketPattern)
+		}
+		// Lists are replaced, mappings merged
+		if !slices.Equal(config.ShardLabels, []string{"sd_bucket"}) {
+			t.Errorf("Expected the shard labels of the profile, got %v", config.ShardLabels)
+		}
+		if settings := config.jobSettings("minio-buckets"); settings.ScrapeInterval != time.Minute || settings.ScrapeTimeout != 30*time.Second {
+			t.Errorf("Expected the merged job settings 1m/30s, got %+v", settings)
+		}
+	})
+
+	t.Run("environment variable", func(t *testing.T) {
+		t.Setenv("PROFILE", "prod") // Commonly set for other purposes, ignored
+		t.Setenv("EOS_SD_PROFILE", "empty")
+		config, err := (&ConfigLoader{path: path}).Load()
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if config.Profile != "empty" || config.Sources["profile"] != SourceEnv || config.BucketPattern != "*" {
+			t.Errorf("Expected the empty profile from $EOS_SD_PROFILE with the base settings, got %q from %s with %q", config.Profile, config.Sources["profile"], config.BucketPattern)
+		}
+	})
+
+	t.Run("selected profile without config file", func(t *testing.T) {
+		_, err := (&ConfigLoader{path: filepath.Join(t.TempDir(), "missing.yaml"), flags: map[string]string{"profile": "prod"}}).Load()
+		if err == nil || !strings.Contains(err.Error(), "profile prod is selected but no config file is loaded") {
+			t.Errorf("Expected an error for a profile without config file, got %v", err)
+		}
+	})
+}
+
+func TestLoadConfigFromFileProfileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		profile string
+		message string
+	}{
+		{"unknown profile", profilesConfig, "staging", "unknown profile staging, the config file defines dev, empty, prod"},
+		{"no profiles", "bucket_pattern: \"*\"\n", "prod", "profile prod is selected but the config file defines no profiles"},
+		{"nested profiles", "profiles:\n  prod:\n    profiles: {}\n", "", "line 3: profile prod must not set profiles"},
+		{"profile selection", "profiles:\n  prod:\n    profile: dev\n", "", "line 3: profile prod must not set profile"},
+		{"not a mapping", "profiles:\n  prod: prod-*\n", "", "line 2: profile prod must be a mapping of settings"},
+		{"unknown key", "profiles:\n  prod:\n    bucket_patern: prod-*\n", "", `line 3: unknown key "bucket_patern", did you mean "bucket_pattern"?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+			_, err := loadConfigFromFile(path, tt.profile)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// PrometheusConfig is the part of a Prometheus configuration file rendered by this service
+type PrometheusConfig struct {
+	ScrapeConfigs []PrometheusScrapeConfig `yaml:"scrape_configs"`
+}
+
+// PrometheusScrapeConfig is a Prometheus scrape_config using HTTP service discovery
+type PrometheusScrapeConfig struct {
+	JobName        string                    `yaml:"job_name"`
+	ScrapeInterval string                    `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout  string                    `yaml:"scrape_timeout,omitempty"`
+	MetricsPath    string                    `yaml:"metrics_path,omitempty"`
+	Scheme         string                    `yaml:"scheme,omitempty"`
+	Authorization  *PrometheusAuthorization  `yaml:"authorization,omitempty"`
+	HTTPSDConfigs  []PrometheusHTTPSDConfig  `yaml:"http_sd_configs"`
+	RelabelConfigs []PrometheusRelabelConfig `yaml:"relabel_configs,omitempty"`
+}
+
+// PrometheusHTTPSDConfig is a Prometheus http_sd_config
+type PrometheusHTTPSDConfig struct {
+	URL             string                   `yaml:"url"`
+	RefreshInterval string                   `yaml:"refresh_interval,omitempty"`
+	BasicAuth       *PrometheusBasicAuth     `yaml:"basic_auth,omitempty"`
+	Authorization   *PrometheusAuthorization `yaml:"authorization,omitempty"`
+	TLSConfig       *PrometheusTLSConfig     `yaml:"tls_config,omitempty"`
+}
+
+// PrometheusAuthorization is the Prometheus authorization block reading credentials from a file
+type PrometheusAuthorization struct {
+	Type            string `yaml:"type,omitempty"`
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+// PrometheusBasicAuth is the Prometheus basic_auth block reading the password from a file
+type PrometheusBasicAuth struct {
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// PrometheusTLSConfig is the Prometheus tls_config block
+type PrometheusTLSConfig struct {
+	CAFile   string `yaml:"ca_file,omitempty"`
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+}
+
+// Placeholder paths on the Prometheus host for the /sd credentials and certificates this
+// service has no setting for. They have to be replaced when the files live elsewhere.
+const (
+	prometheusSDCAFile       = "/etc/prometheus/eos_sd/ca.crt"
+	prometheusSDCertFile     = "/etc/prometheus/eos_sd/client.crt"
+	prometheusSDKeyFile      = "/etc/prometheus/eos_sd/client.key"
+	prometheusSDPasswordFile = "/etc/prometheus/eos_sd/password"
+	prometheusSDTokenFile    = "/etc/prometheus/eos_sd/token"
+)
+
+// PrometheusRelabelConfig is a Prometheus relabel_config
+type PrometheusRelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow,omitempty"`
+	Separator    string   `yaml:"separator,omitempty"`
+	Regex        string   `yaml:"regex,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+	Action       string   `yaml:"action,omitempty"`
+}
+
+// externalURL returns the URL Prometheus uses to reach this service. Without an explicit
+// external URL it is derived from the listen address, which only works when Prometheus
+// runs on the same host.
+func (m *MinIOClient) externalURL() string {
+	if m.cfg().ExternalURL != "" {
+		return strings.TrimSuffix(m.cfg().ExternalURL, "/")
+	}
+
+	scheme := "http"
+	if m.cfg().TLSEnabled {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(m.cfg().ListenAddr)
+	if err != nil {
+		return scheme + "://" + m.cfg().ListenAddr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// jobRelabelConfigs returns the relabel rules a job needs for the labels emitted on /sd.
+// Every minio-buckets target shares the same address, so the instance label is made
+// unique per bucket to tell the targets apart in up and scrape_* series.
+func jobRelabelConfigs(job string) []PrometheusRelabelConfig {
+	switch job {
+	case "minio-buckets":
+		return []PrometheusRelabelConfig{
+			{
+				SourceLabels: []string{"__address__", "sd_bucket"},
+				Separator:    "/",
+				TargetLabel:  "instance",
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+// sdCredentials returns the credentials Prometheus presents to /sd. prometheus_sd_token_file
+// is used when set; otherwise, when /sd requires authentication, the first basic or bearer
+// method of its policy is filled in with a placeholder file. Client certificates are
+// covered by sdTLSConfig.
+func (m *MinIOClient) sdCredentials() (*PrometheusAuthorization, *PrometheusBasicAuth) {
+	config := m.cfg()
+	if config.PrometheusSDTokenFile != "" {
+		return &PrometheusAuthorization{Type: "Bearer", CredentialsFile: config.PrometheusSDTokenFile}, nil
+	}
+	if !config.Auth.Enabled() {
+		return nil, nil
+	}
+	methods := config.Auth.policy("/sd")
+	if slices.Contains(methods, AuthMethodNone) {
+		return nil, nil
+	}
+	for _, method := range methods {
+		switch method {
+		case AuthMethodBasic:
+			if users := slices.Sorted(maps.Keys(config.Auth.BasicAuthUsers)); len(users) > 0 {
+				return nil, &PrometheusBasicAuth{Username: users[0], PasswordFile: prometheusSDPasswordFile}
+			}
+		case AuthMethodBearer:
+			return &PrometheusAuthorization{Type: "Bearer", CredentialsFile: prometheusSDTokenFile}, nil
+		}
+	}
+	return nil, nil
+}
+
+// sdTLSConfig returns the tls_config Prometheus needs to reach /sd over HTTPS, with a client
+// certificate when the listener verifies them
+func (m *MinIOClient) sdTLSConfig() *PrometheusTLSConfig {
+	if !m.cfg().TLSEnabled {
+		return nil
+	}
+	tlsConfig := &PrometheusTLSConfig{CAFile: prometheusSDCAFile}
+	if m.cfg().TLSServer.verifiesClientCerts() {
+		tlsConfig.CertFile = prometheusSDCertFile
+		tlsConfig.KeyFile = prometheusSDKeyFile
+	}
+	return tlsConfig
+}
+
+// GeneratePrometheusConfig renders a Prometheus configuration that discovers every job of this
+// service through http_sd_configs pointing at its external URL
+func (m *MinIOClient) GeneratePrometheusConfig(ctx context.Context) (PrometheusConfig, error) {
+	configs, err := m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		return PrometheusConfig{}, err
+	}
+
+	sdAuthorization, sdBasicAuth := m.sdCredentials()
+	sdTLSConfig := m.sdTLSConfig()
+	var minioAuthorization *PrometheusAuthorization
+	if m.cfg().PrometheusMinIOTokenFile != "" {
+		minioAuthorization = &PrometheusAuthorization{Type: "Bearer", CredentialsFile: m.cfg().PrometheusMinIOTokenFile}
+	}
+
+	prometheusConfig := PrometheusConfig{ScrapeConfigs: []PrometheusScrapeConfig{}}
+	for _, config := range configs {
+		prometheusConfig.ScrapeConfigs = append(prometheusConfig.ScrapeConfigs, PrometheusScrapeConfig{
+			JobName:        config.JobName,
+			ScrapeInterval: config.ScrapeInterval,
+			ScrapeTimeout:  config.ScrapeTimeout,
+			MetricsPath:    config.MetricsPath,
+			Scheme:         config.Scheme,
+			Authorization:  minioAuthorization,
+			HTTPSDConfigs: []PrometheusHTTPSDConfig{
+				{
+					URL:             m.externalURL() + "/sd?job=" + url.QueryEscape(config.JobName),
+					RefreshInterval: formatDuration(m.cfg().ScrapeInterval),
+					BasicAuth:       sdBasicAuth,
+					Authorization:   sdAuthorization,
+					TLSConfig:       sdTLSConfig,
+				},
+			},
+			RelabelConfigs: jobRelabelConfigs(config.JobName),
+		})
+	}
+	return prometheusConfig, nil
+}
+
+// RenderPrometheusConfig renders the generated Prometheus configuration as YAML
+func (m *MinIOClient) RenderPrometheusConfig(ctx context.Context) ([]byte, error) {
+	prometheusConfig, err := m.GeneratePrometheusConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Prometheus config: %w", err)
+	}
+	data, err := yaml.Marshal(prometheusConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Prometheus config: %w", err)
+	}
+	return data, nil
+}
+
+// handlePrometheusConfig handles the /prometheus/scrape_configs.yaml endpoint
+func (m *MinIOClient) handlePrometheusConfig(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("Prometheus config request from %s", r.RemoteAddr)
+
+	data, err := m.RenderPrometheusConfig(r.Context())
+	if err != nil {
+		logrus.Errorf("Failed to render Prometheus config: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHandlePrometheusConfig(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ExternalURL = "https://sd.example.com/"
+		c.PrometheusMinIOTokenFile = "/etc/prometheus/minio.token"
+		c.ScrapeInterval = 15 * time.Second
+	})
+
+	recorder := httptest.NewRecorder()
+	client.handlePrometheusConfig(recorder, httptest.NewRequest(http.MethodGet, "/prometheus/scrape_configs.yaml", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var config PrometheusConfig
+	if err := yaml.Unmarshal(recorder.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(config.ScrapeConfigs) != 2 {
+		t.Fatalf("Expected 2 scrape configs, got %d", len(config.ScrapeConfigs))
+	}
+
+	for _, job := range config.ScrapeConfigs {
+		if job.ScrapeInterval != "15s" || job.ScrapeTimeout != "10s" || job.Scheme != "http" {
+			t.Errorf("Job %s: unexpected interval/timeout/scheme %s/%s/%s", job.JobName, job.ScrapeInterval, job.ScrapeTimeout, job.Scheme)
+		}
+		if job.Authorization == nil || job.Authorization.CredentialsFile != "/etc/prometheus/minio.token" {
+			t.Errorf("Job %s: expected MinIO bearer token file, got %+v", job.JobName, job.Authorization)
+		}
+		if len(job.HTTPSDConfigs) != 1 || job.HTTPSDConfigs[0].URL != "https://sd.example.com/sd?job="+job.JobName {
+			t.Errorf("Job %s: unexpected http_sd_configs %+v", job.JobName, job.HTTPSDConfigs)
+		}
+		if job.HTTPSDConfigs[0].Authorization != nil {
+			t.Errorf("Job %s: expected no SD authorization, got %+v", job.JobName, job.HTTPSDConfigs[0].Authorization)
+		}
+	}
+
+	buckets := config.ScrapeConfigs[1]
+	if buckets.JobName != "minio-buckets" || len(buckets.RelabelConfigs) != 1 || buckets.RelabelConfigs[0].TargetLabel != "instance" {
+		t.Errorf("Expected instance relabeling for minio-buckets, got %+v", buckets)
+	}
+}
+
+func TestPrometheusConfigSDCredentials(t *testing.T) {
+	const hash = "$2y$10$mDwo.lAisC94iLAyP81MCesa29IzH37oigHC/42V2pdJlUprsJPze"
+	tests := []struct {
+		name          string
+		configure     func(*Config)
+		basicAuth     *PrometheusBasicAuth
+		authorization *PrometheusAuthorization
+		tlsConfig     *PrometheusTLSConfig
+	}{
+		{"no auth", func(*Config) {}, nil, nil, nil},
+		{"basic auth over tls", func(c *Config) {
+			c.Auth.BasicAuthUsers = map[string]string{"prometheus": hash, "grafana": hash}
+			c.TLSEnabled = true
+			c.TLSServer = &TLSServerConfig{CertFile: "server.crt", KeyFile: "server.key"}
+		}, &PrometheusBasicAuth{Username: "grafana", PasswordFile: prometheusSDPasswordFile}, nil, &PrometheusTLSConfig{CAFile: prometheusSDCAFile}},
+		{"bearer default policy with client certificates", func(c *Config) {
+			c.Auth.BasicAuthUsers = map[string]string{"prometheus": hash}
+			c.Auth.BearerTokenHashes = []string{"2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}
+			c.Auth.DefaultPolicy = []string{AuthMethodBearer, AuthMethodBasic}
+			c.TLSEnabled = true
+			c.TLSServer = &TLSServerConfig{CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt"}
+		}, nil, &PrometheusAuthorization{Type: "Bearer", CredentialsFile: prometheusSDTokenFile}, &PrometheusTLSConfig{CAFile: prometheusSDCAFile, CertFile: prometheusSDCertFile, KeyFile: prometheusSDKeyFile}},
+		{"open sd route", func(c *Config) {
+			c.Auth.BasicAuthUsers = map[string]string{"prometheus": hash}
+			c.Auth.RoutePolicies = map[string][]string{"/sd": {AuthMethodNone}}
+		}, nil, nil, nil},
+		{"configured token file", func(c *Config) {
+			c.Auth.BasicAuthUsers = map[string]string{"prometheus": hash}
+			c.PrometheusSDTokenFile = "/etc/prometheus/sd.token"
+		}, nil, &PrometheusAuthorization{Type: "Bearer", CredentialsFile: "/etc/prometheus/sd.token"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestMinIOClient(t, tt.configure)
+			prometheusConfig, err := client.GeneratePrometheusConfig(context.Background())
+			if err != nil {
+				t.Fatalf("GeneratePrometheusConfig failed: %v", err)
+			}
+			for _, job := range prometheusConfig.ScrapeConfigs {
+				sd := job.HTTPSDConfigs[0]
+				if !reflect.DeepEqual(sd.BasicAuth, tt.basicAuth) || !reflect.DeepEqual(sd.Authorization, tt.authorization) || !reflect.DeepEqual(sd.TLSConfig, tt.tlsConfig) {
+					t.Errorf("Job %s: expected %+v %+v %+v, got %+v %+v %+v", job.JobName, tt.basicAuth, tt.authorization, tt.tlsConfig, sd.BasicAuth, sd.Authorization, sd.TLSConfig)
+				}
+			}
+		})
+	}
+}
+
+func TestExternalURL(t *testing.T) {
+	tests := []struct {
+		listenAddr string
+		tls        bool
+		expected   string
+	}{
+		{":8080", false, "http://localhost:8080"},
+		{"0.0.0.0:8080", true, "https://localhost:8080"},
+		{"10.0.0.5:9090", false, "http://10.0.0.5:9090"},
+	}
+
+	for _, tt := range tests {
+		client := newTestMinIOClient(t, func(c *Config) {
+			c.ListenAddr = tt.listenAddr
+			c.TLSEnabled = tt.tls
+		})
+		if got := client.externalURL(); got != tt.expected {
+			t.Errorf("externalURL() for %s (tls=%t) = %s, expected %s", tt.listenAddr, tt.tls, got, tt.expected)
+		}
+	}
+}
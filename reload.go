@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// What caused a configuration (re)load
+const (
+	ReloadTriggerStartup    = "startup"
+	ReloadTriggerSignal     = "sighup"
+	ReloadTriggerFileChange = "file_change"
+	ReloadTriggerAPI        = "api"
+)
+
+// configWatchInterval is how often the config file is checked for changes
+const configWatchInterval = 5 * time.Second
+
+// ReloadStatus describes the outcome of the last configuration load or reload
+type ReloadStatus struct {
+	Success         bool      `json:"success"`
+	Time            time.Time `json:"time"`
+	Trigger         string    `json:"trigger"`
+	Error           string    `json:"error,omitempty"`
+	Changed         []string  `json:"changed,omitempty"`          // Settings whose value changed
+	RestartRequired []string  `json:"restart_required,omitempty"` // Changed settings kept at their current value until a restart
+	Reconnected     bool      `json:"reconnected,omitempty"`      // MinIO clients were rebuilt for new connection settings
+}
+
+// ConfigLoader re-reads the config file and combines it with the flags and environment
+// variables the service was started with
+type ConfigLoader struct {
+	path     string
+	required bool              // The file was loaded at startup, so failing to read it later is an error
+	flags    map[string]string // Settings given on the command line
+}
+
+// Load reads and combines the configuration, including the web config file
+func (l *ConfigLoader) Load() (Config, error) {
+	fileConfig := &ConfigFile{}
+	if l.path != "" {
+		loaded, err := loadConfigFromFile(l.path, selectedProfile(l.flags))
+		switch {
+		case err == nil:
+			fileConfig = loaded
+		case l.required || !errors.Is(err, fs.ErrNotExist):
+			return Config{}, err
+		}
+	}
+	if profile := selectedProfile(l.flags); profile != "" && fileConfig.profile == "" {
+		return Config{}, fmt.Errorf("profile %s is selected but no config file is loaded", profile)
+	}
+
+	config, err := buildConfig(fileConfig, l.flags)
+	if err != nil {
+		return Config{}, err
+	}
+	if _, err := applyWebConfig(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid web config: %w", err)
+	}
+	return config, nil
+}
+
+// fingerprint returns a hash of the content of the config file and the given secret files.
+// Files that cannot be read are left out.
+func (l *ConfigLoader) fingerprint(secretFiles ...string) string {
+	hash := sha256.New()
+	for _, path := range append([]string{l.path}, secretFiles...) {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", path, len(data))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// connectionChanged reports whether the MinIO clients must be rebuilt for a new configuration
+func connectionChanged(old, updated Config) bool {
+	return old.MinIOEndpoint != updated.MinIOEndpoint ||
+		old.MinIOAccessKey != updated.MinIOAccessKey ||
+		old.MinIOSecretKey != updated.MinIOSecretKey ||
+		old.MinIOUseSSL != updated.MinIOUseSSL
+}
+
+// changedSettings lists the settings whose value differs between two configurations
+func changedSettings(old, updated Config) []string {
+	var changed []string
+	oldSettings := effectiveConfig(old)
+	for i, setting := range effectiveConfig(updated) {
+		if setting.Value != oldSettings[i].Value {
+			changed = append(changed, setting.Key)
+		}
+	}
+	// Masked credentials may look identical while being different
+	if old.MinIOAccessKey != updated.MinIOAccessKey && !slices.Contains(changed, "minio_access_key") {
+		changed = append(changed, "minio_access_key")
+	}
+	if old.MinIOSecretKey != updated.MinIOSecretKey && !slices.Contains(changed, "minio_secret_key") {
+		changed = append(changed, "minio_secret_key")
+	}
+	if !reflect.DeepEqual(old.Auth, updated.Auth) && !slices.ContainsFunc(changed, func(key string) bool { return strings.HasPrefix(key, "auth.") }) {
+		changed = append(changed, "auth")
+	}
+	return changed
+}
+
+// keepStartupSettings resets the settings that are only read at startup (listeners, TLS,
+// metric labels) to their current value, and returns the names of those
+// that differed
+func keepStartupSettings(config *Config, current Config) []string {
+	var kept []string
+	config.Sources = maps.Clone(config.Sources)
+	keep := func(name string, differs bool, reset func()) {
+		if differs {
+			kept = append(kept, name)
+			reset()
+			if config.Sources != nil {
+				config.Sources[name] = current.Sources[name]
+			}
+		}
+	}
+	keep("listen_addr", config.ListenAddr != current.ListenAddr, func() { config.ListenAddr = current.ListenAddr })
+	keep("web_config_file", config.WebConfigFile != current.WebConfigFile, func() { config.WebConfigFile = current.WebConfigFile })
+	keep("cluster_name", config.ClusterName != current.ClusterName, func() { config.ClusterName = current.ClusterName })
+	keep("consul_listen_addr", config.ConsulListenAddr != current.ConsulListenAddr, func() { config.ConsulListenAddr = current.ConsulListenAddr })
+	keep("dns_listen_addr", config.DNSListenAddr != current.DNSListenAddr, func() { config.DNSListenAddr = current.DNSListenAddr })
+	keep("dns_domain", config.DNSDomain != current.DNSDomain, func() { config.DNSDomain = current.DNSDomain })
+	// The file_sd writer can switch directories but is only started when file_sd_dir is set at startup
+	keep("file_sd_dir", (config.FileSDDir == "") != (current.FileSDDir == ""), func() { config.FileSDDir = current.FileSDDir })
+	// The TLS settings of the web config file are only read when the listener starts
+	if config.WebConfigFile == current.WebConfigFile && !reflect.DeepEqual(config.TLSServer, current.TLSServer) {
+		kept = append(kept, "web_config_file (tls_server_config)")
+	}
+	config.TLSEnabled = current.TLSEnabled
+	config.TLSServer = current.TLSServer
+	return kept
+}
+
+// ApplyConfig validates a configuration and atomically puts it into effect. The MinIO
+// clients are only rebuilt when the connection settings changed; on any error the current
+// configuration stays in effect.
+func (m *MinIOClient) ApplyConfig(config Config) (ReloadStatus, error) {
+	if err := validateConfig(config); err != nil {
+		return ReloadStatus{}, err
+	}
+
+	current := m.state.Load()
+	status := ReloadStatus{
+		Changed:     changedSettings(current.config, config),
+		Reconnected: connectionChanged(current.config, config),
+	}
+	status.RestartRequired = keepStartupSettings(&config, current.config)
+
+	state := &clientState{config: config, client: current.client, admin: current.admin}
+	if status.Reconnected {
+		var err error
+		if state, err = newClientState(config); err != nil {
+			return ReloadStatus{}, err
+		}
+	}
+	// Built before anything is applied, so that an unreadable token file keeps the current configuration
+	authenticator, err := newAuthenticatorFor(config.Auth)
+	if err != nil {
+		return ReloadStatus{}, fmt.Errorf("auth: %w", err)
+	}
+
+	m.breaker.Configure(config.BreakerFailureThreshold, config.BackoffInitial, config.BackoffMax)
+	if level, err := logrus.ParseLevel(config.LogLevel); err == nil {
+		logrus.SetLevel(level)
+	}
+	m.state.Store(state)
+	m.authenticator.Store(authenticator)
+	if status.Reconnected {
+		// Failures of the previous endpoint no longer apply, and its snapshot is refreshed on next use
+		m.breaker.Reset()
+		m.invalidateSnapshot()
+	}
+	return status, nil
+}
+
+// LastReload returns the outcome of the last configuration load or reload
+func (m *MinIOClient) LastReload() ReloadStatus {
+	return *m.lastReload.Load()
+}
+
+// Reloader reloads the configuration on SIGHUP, on changes of the config file or credential
+// files, and on POST /-/reload
+type Reloader struct {
+	m      *MinIOClient
+	loader *ConfigLoader
+
+	mu          sync.Mutex // Serializes reloads
+	fingerprint string     // Config and credential file content of the last reload attempt, over the files of the configuration in effect
+}
+
+// NewReloader creates a reloader for the configuration read by loader
+func NewReloader(m *MinIOClient, loader *ConfigLoader) *Reloader {
+	return &Reloader{m: m, loader: loader, fingerprint: loader.fingerprint(m.cfg().secretFiles()...)}
+}
+
+// Reload re-reads, validates and applies the configuration, keeping the current one on failure
+func (r *Reloader) Reload(trigger string) ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fingerprint = r.loader.fingerprint(r.m.cfg().secretFiles()...)
+	config, err := r.loader.Load()
+	var status ReloadStatus
+	if err == nil {
+		status, err = r.m.ApplyConfig(config)
+	}
+	if err == nil {
+		// The new configuration may watch other credential files than the previous one
+		r.fingerprint = r.loader.fingerprint(r.m.cfg().secretFiles()...)
+	}
+	status.Time = time.Now()
+	status.Trigger = trigger
+
+	if err != nil {
+		status.Error = err.Error()
+		logrus.Errorf("Config reload (%s) failed, keeping the current configuration: %v", trigger, err)
+	} else {
+		status.Success = true
+		logrus.Infof("Config reloaded (%s); changed settings: %v", trigger, status.Changed)
+		if status.Reconnected {
+			logrus.Infof("MinIO clients recreated for endpoint %s", config.MinIOEndpoint)
+		}
+		if len(status.RestartRequired) > 0 {
+			logrus.Warnf("Config reload (%s): %v only take effect after a restart", trigger, status.RestartRequired)
+		}
+	}
+
+	r.m.lastReload.Store(&status)
+	r.m.metrics.ObserveReload(status)
+	return status
+}
+
+// Run reloads the configuration on SIGHUP and when the content of the config file or the MinIO
+// credential files changes, until ctx is cancelled
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload(ReloadTriggerSignal)
+		case <-ticker.C:
+			r.mu.Lock()
+			changed := r.loader.fingerprint(r.m.cfg().secretFiles()...) != r.fingerprint
+			r.mu.Unlock()
+			if changed {
+				r.Reload(ReloadTriggerFileChange)
+			}
+		}
+	}
+}
+
+// handleReload handles POST /-/reload. Like Prometheus, it returns 500 when the new
+// configuration could not be applied.
+func (r *Reloader) handleReload(w http.ResponseWriter, req *http.Request) {
+	logrus.Infof("Config reload requested by %s", req.RemoteAddr)
+	status := r.Reload(ReloadTriggerAPI)
+
+	code := http.StatusOK
+	if !status.Success {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, status)
+}
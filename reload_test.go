@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestApplyConfig(t *testing.T) {
+	t.Run("invalid config keeps the current one", func(t *testing.T) {
+		config := testConfig()
+		client := newTestMinIOClient(t)
+		config.BucketPattern = "logs-*"
+		config.ScrapeInterval = 0
+		if _, err := client.ApplyConfig(config); err == nil {
+			t.Fatal("Expected an error for a zero scrape interval")
+		}
+		if got := client.cfg().BucketPattern; got != "*" {
+			t.Errorf("Expected the bucket pattern to stay *, got %q", got)
+		}
+	})
+
+	t.Run("filter change keeps the clients", func(t *testing.T) {
+		config := testConfig()
+		client := newTestMinIOClient(t)
+		minioClient := client.state.Load().client
+		config.BucketPattern = "logs-*"
+		status, err := client.ApplyConfig(config)
+		if err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+		if status.Reconnected {
+			t.Error("Expected no reconnect for a bucket pattern change")
+		}
+		if !slices.Equal(status.Changed, []string{"bucket_pattern"}) {
+			t.Errorf("Expected changed [bucket_pattern], got %v", status.Changed)
+		}
+		if got := client.cfg().BucketPattern; got != "logs-*" {
+			t.Errorf("Expected bucket pattern logs-*, got %q", got)
+		}
+		if client.state.Load().client != minioClient {
+			t.Error("Expected the MinIO client to be kept")
+		}
+	})
+
+	t.Run("endpoint change reconnects", func(t *testing.T) {
+		config := testConfig()
+		client := newTestMinIOClient(t)
+		client.snapshot = &Snapshot{Cluster: "test", UpdatedAt: time.Now(), RefreshedAt: time.Now()}
+		minioClient := client.state.Load().client
+		config.MinIOEndpoint = "minio.example.com:9000"
+		config.MinIOSecretKey = "rotated"
+		status, err := client.ApplyConfig(config)
+		if err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+		if !status.Reconnected {
+			t.Error("Expected a reconnect for an endpoint change")
+		}
+		if !slices.Contains(status.Changed, "minio_secret_key") {
+			t.Errorf("Expected minio_secret_key among the changed settings, got %v", status.Changed)
+		}
+		if client.state.Load().client == minioClient {
+			t.Error("Expected a new MinIO client")
+		}
+		if got := client.state.Load().client.EndpointURL().Host; got != "minio.example.com:9000" {
+			t.Errorf("Expected the new client to use minio.example.com:9000, got %s", got)
+		}
+		if !client.currentSnapshot().RefreshedAt.IsZero() {
+			t.Error("Expected the snapshot of the previous endpoint to be refreshed on next use")
+		}
+	})
+
+	t.Run("startup settings require a restart", func(t *testing.T) {
+		config := testConfig()
+		client := newTestMinIOClient(t)
+		config.ListenAddr = ":9090"
+		config.BucketPattern = "logs-*"
+		status, err := client.ApplyConfig(config)
+		if err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+		if !slices.Equal(status.RestartRequired, []string{"listen_addr"}) {
+			t.Errorf("Expected restart_required [listen_addr], got %v", status.RestartRequired)
+		}
+		if got := client.cfg().ListenAddr; got != ":8080" {
+			t.Errorf("Expected listen_addr to stay :8080, got %q", got)
+		}
+		if got := client.cfg().BucketPattern; got != "logs-*" {
+			t.Errorf("Expected bucket pattern logs-*, got %q", got)
+		}
+	})
+
+	t.Run("tls server changes require a restart", func(t *testing.T) {
+		config := testConfig()
+		config.WebConfigFile = "web.yml"
+		config.TLSEnabled = true
+		config.TLSServer = &TLSServerConfig{CertFile: "server.crt", KeyFile: "server.key"}
+		client, err := NewMinIOClient(config)
+		if err != nil {
+			t.Fatalf("Failed to create MinIO client: %v", err)
+		}
+		config.TLSServer = &TLSServerConfig{CertFile: "server.crt", KeyFile: "server.key", MinVersion: "TLS13"}
+		status, err := client.ApplyConfig(config)
+		if err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+		if !slices.Equal(status.RestartRequired, []string{"web_config_file (tls_server_config)"}) {
+			t.Errorf("Expected restart_required [web_config_file (tls_server_config)], got %v", status.RestartRequired)
+		}
+		if got := client.cfg().TLSServer.MinVersion; got != "" {
+			t.Errorf("Expected the running TLS settings to be kept, got min_version %q", got)
+		}
+	})
+}
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	write("bucket_pattern: \"*\"\n")
+
+	loader := &ConfigLoader{path: path, required: true, flags: map[string]string{"cluster_name": "test"}}
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	client, err := NewMinIOClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create MinIO client: %v", err)
+	}
+	reloader := NewReloader(client, loader)
+
+	write("bucket_pattern: \"logs-*\"\n")
+	status := reloader.Reload(ReloadTriggerAPI)
+	if !status.Success || status.Trigger != ReloadTriggerAPI || len(status.RestartRequired) > 0 {
+		t.Fatalf("Expected a successful api reload, got %+v", status)
+	}
+	if got := client.cfg().BucketPattern; got != "logs-*" {
+		t.Errorf("Expected bucket pattern logs-*, got %q", got)
+	}
+	if got := client.cfg().Sources["cluster_name"]; got != SourceFlag {
+		t.Errorf("Expected cluster_name to keep coming from the flag, got %q", got)
+	}
+	if got := testutil.ToFloat64(client.metrics.reloadSuccess); got != 1 {
+		t.Errorf("Expected eos_sd_config_last_reload_successful 1, got %v", got)
+	}
+
+	write("bucket_pattern: [unclosed\n")
+	status = reloader.Reload(ReloadTriggerSignal)
+	if status.Success || status.Error == "" {
+		t.Fatalf("Expected a failed reload for invalid YAML, got %+v", status)
+	}
+	if got := client.cfg().BucketPattern; got != "logs-*" {
+		t.Errorf("Expected bucket pattern to stay logs-*, got %q", got)
+	}
+	if got := client.LastReload(); got.Success || got.Trigger != ReloadTriggerSignal {
+		t.Errorf("Expected the failed sighup reload to be reported, got %+v", got)
+	}
+	if got := testutil.ToFloat64(client.metrics.reloadSuccess); got != 0 {
+		t.Errorf("Expected eos_sd_config_last_reload_successful 0, got %v", got)
+	}
+}
+
+func TestReloadRotatesAuthCredentials(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	tokenFile := filepath.Join(dir, "token")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	write(path, fmt.Sprintf("bucket_pattern: \"*\"\nauth:\n  bearer_token_files: [%q]\n", tokenFile))
+	write(tokenFile, "old-token\n")
+
+	loader := &ConfigLoader{path: path, required: true}
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	client, err := NewMinIOClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create MinIO client: %v", err)
+	}
+	reloader := NewReloader(client, loader)
+
+	handler := client.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	statusFor := func(token string) int {
+		request := httptest.NewRequest(http.MethodGet, "/sd", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	if got := statusFor("old-token"); got != http.StatusOK {
+		t.Fatalf("Expected the old token to be accepted, got %d", got)
+	}
+
+	fingerprint := loader.fingerprint(client.cfg().secretFiles()...)
+	write(tokenFile, "new-token\n")
+	if loader.fingerprint(client.cfg().secretFiles()...) == fingerprint {
+		t.Errorf("Expected a rotated token file to change the reload fingerprint")
+	}
+	if status := reloader.Reload(ReloadTriggerFileChange); !status.Success || len(status.RestartRequired) > 0 {
+		t.Fatalf("Expected a successful reload, got %+v", status)
+	}
+	if got := statusFor("new-token"); got != http.StatusOK {
+		t.Errorf("Expected the rotated token to be accepted, got %d", got)
+	}
+	if got := statusFor("old-token"); got != http.StatusUnauthorized {
+		t.Errorf("Expected the old token to be rejected, got %d", got)
+	}
+
+	// Removing every credential turns authentication off
+	write(path, "bucket_pattern: \"*\"\n")
+	if status := reloader.Reload(ReloadTriggerFileChange); !status.Success || !slices.Contains(status.Changed, "auth.bearer_token_files") {
+		t.Fatalf("Expected a successful reload changing auth, got %+v", status)
+	}
+	// The token file is no longer watched, so the next poll must not reload again
+	if reloader.fingerprint != loader.fingerprint(client.cfg().secretFiles()...) {
+		t.Error("Expected the fingerprint to cover the files of the applied configuration")
+	}
+	if got := statusFor(""); got != http.StatusOK {
+		t.Errorf("Expected requests without credentials to be accepted, got %d", got)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query parameters scoping the target groups returned by /sd
+const (
+	ScopeParamBucketPattern = "bucket_pattern"
+	ScopeParamExclude       = "exclude"
+	ScopeParamCluster       = "cluster"
+	ScopeParamPool          = "pool"
+	ScopeParamLabelPrefix   = "label."
+)
+
+// scopeParams are the scoping parameters callers may be allowed to pass; label.<name>
+// parameters are allowed individually or all at once with label.*
+var scopeParams = []string{ScopeParamBucketPattern, ScopeParamExclude, ScopeParamCluster, ScopeParamPool}
+
+// SDScope narrows the target groups of a job for a single /sd request, so that several
+// Prometheus jobs can share one service discovery instance
+type SDScope struct {
+	Filter  BucketFilter
+	Cluster string            // Only return targets of this cluster
+	Pool    int               // Only return nodes of this pool; -1 for all pools
+	Labels  map[string]string // Target group label (or bucket tag) values that must match
+	Shard   int               // Shard to return when Shards is set
+	Shards  int               // Number of hashmod shards; 0 disables sharding
+	scoped  bool
+}
+
+// scopeParamAllowed reports whether the allow-list permits overriding param
+func scopeParamAllowed(allowed []string, param string) bool {
+	if slices.Contains(allowed, param) {
+		return true
+	}
+	return strings.HasPrefix(param, ScopeParamLabelPrefix) && slices.Contains(allowed, ScopeParamLabelPrefix+"*")
+}
+
+// parseSDScope builds the scope of a /sd request from its query parameters. It returns the
+// HTTP status to reply with when a parameter is unknown, not allowed or malformed.
+func (m *MinIOClient) parseSDScope(query url.Values) (SDScope, int, error) {
+	scope := SDScope{Filter: m.bucketFilter(), Pool: -1}
+
+	for param, values := range query {
+		if param == "job" || param == ShardParam || param == ShardsParam {
+			continue
+		}
+		label, isLabel := strings.CutPrefix(param, ScopeParamLabelPrefix)
+		if !slices.Contains(scopeParams, param) && (!isLabel || label == "") {
+			return scope, http.StatusBadRequest, fmt.Errorf("unknown parameter %q", param)
+		}
+		if !scopeParamAllowed(m.cfg().SDAllowedParams, param) {
+			return scope, http.StatusForbidden, fmt.Errorf("parameter %q is not in sd_allowed_params", param)
+		}
+		if len(values) != 1 {
+			return scope, http.StatusBadRequest, fmt.Errorf("parameter %q must be given once", param)
+		}
+
+		value := values[0]
+		scope.scoped = true
+		switch {
+		case param == ScopeParamBucketPattern:
+			scope.Filter.Pattern = value
+		case param == ScopeParamExclude:
+			scope.Filter.ExcludePattern = value
+		case param == ScopeParamCluster:
+			scope.Cluster = value
+		case param == ScopeParamPool:
+			pool, err := strconv.Atoi(value)
+			if err != nil || pool < 0 {
+				return scope, http.StatusBadRequest, fmt.Errorf("invalid pool %q", value)
+			}
+			scope.Pool = pool
+		default:
+			if scope.Labels == nil {
+				scope.Labels = make(map[string]string)
+			}
+			scope.Labels[label] = value
+		}
+	}
+
+	// Sharding does not change what is discovered, so it is not subject to the allow-list
+	shard, shards, err := parseShard(query.Get(ShardParam), query.Get(ShardsParam))
+	if err != nil {
+		return scope, http.StatusBadRequest, err
+	}
+	if shards > 0 {
+		scope.Shard, scope.Shards, scope.scoped = shard, shards, true
+	}
+	return scope, http.StatusOK, nil
+}
+
+// snapshot returns the part of a snapshot within the pool of the scope
+func (s SDScope) snapshot(snapshot *Snapshot) *Snapshot {
+	scoped := *snapshot
+	if s.Pool >= 0 {
+		scoped.Nodes = nil
+		for _, node := range snapshot.Nodes {
+			if slices.Contains(node.Pools, s.Pool) {
+				scoped.Nodes = append(scoped.Nodes, node)
+			}
+		}
+	}
+	return &scoped
+}
+
+// matches reports whether a target group carries every label of the scope. Bucket tags
+// count as labels of the bucket's target group.
+func (s SDScope) matches(group ServiceDiscoveryResponse, snapshot *Snapshot) bool {
+	for name, value := range s.Labels {
+		if actual, ok := group.Labels[name]; ok {
+			if actual != value {
+				return false
+			}
+			continue
+		}
+		bucket, ok := group.Labels["sd_bucket"]
+		if !ok || snapshot.Metadata[bucket].Tags[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// targetGroups returns the target groups of a job within the scope. Unlike the unscoped
+// target groups, they are not recorded in the self-monitoring metrics.
+func (s SDScope) targetGroups(m *MinIOClient, targetConfig ScrapeConfig, snapshot *Snapshot) []ServiceDiscoveryResponse {
+	groups := []ServiceDiscoveryResponse{}
+	if s.Cluster != "" && s.Cluster != snapshot.Cluster {
+		return groups
+	}
+
+	scoped := s.snapshot(snapshot)
+	for _, group := range m.buildTargetGroups(targetConfig, scoped, s.Filter) {
+		if s.matches(group, scoped) {
+			groups = append(groups, group)
+		}
+	}
+	if s.Shards == 0 {
+		return groups
+	}
+
+	sharded := []ServiceDiscoveryResponse{}
+	for _, group := range splitByAddress(groups, m.cfg().ShardLabels) {
+		if shardOf(group, m.cfg().ShardLabels, s.Shards) == s.Shard {
+			sharded = append(sharded, group)
+		}
+	}
+	return sharded
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestServiceDiscoveryScope(t *testing.T) {
+	config := testConfig(func(c *Config) {
+		c.SDAllowedParams = []string{"bucket_pattern", "exclude", "cluster", "pool", "label.*"}
+	})
+	client, err := NewMinIOClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create MinIO client: %v", err)
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes: []NodeInfo{
+			{Endpoint: "node1:9000", State: "online", Pools: []int{1}},
+			{Endpoint: "node2:9000", State: "online", Pools: []int{2}},
+		},
+		Buckets: []minio.BucketInfo{
+			{Name: "prod-payments", CreationDate: created},
+			{Name: "prod-tmp", CreationDate: created},
+			{Name: "prod-search", CreationDate: created},
+			{Name: "dev-payments", CreationDate: created},
+		},
+		Metadata: map[string]BucketMetadata{
+			"prod-payments": {Tags: map[string]string{"team": "payments"}},
+			"prod-tmp":      {Tags: map[string]string{"team": "payments"}},
+			"prod-search":   {Tags: map[string]string{"team": "search"}},
+		},
+	}
+
+	query := func(url string) (int, []ServiceDiscoveryResponse) {
+		recorder := httptest.NewRecorder()
+		client.handleServiceDiscovery(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		var groups []ServiceDiscoveryResponse
+		if recorder.Code == http.StatusOK {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &groups); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return recorder.Code, groups
+	}
+	buckets := func(groups []ServiceDiscoveryResponse) []string {
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Labels["sd_bucket"])
+		}
+		return names
+	}
+
+	code, groups := query("/sd?job=minio-buckets&bucket_pattern=prod-*&exclude=*tmp*&label.team=payments&pool=2")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if names := buckets(groups); !slices.Equal(names, []string{"prod-payments"}) {
+		t.Errorf("Expected only prod-payments, got %v", names)
+	}
+	if len(groups) == 1 && !slices.Equal(groups[0].Targets, []string{"node2:9000"}) {
+		t.Errorf("Expected only the pool 2 node, got %v", groups[0].Targets)
+	}
+
+	if code, groups := query("/sd?job=minio-server&cluster=eu1"); code != http.StatusOK || len(groups) != 0 {
+		t.Errorf("Expected no target groups for another cluster, got %d %v", code, groups)
+	}
+	if code, groups := query("/sd?job=minio-buckets"); code != http.StatusOK || len(groups) != 4 {
+		t.Errorf("Expected all 4 buckets without scope, got %d %v", code, buckets(groups))
+	}
+	if code, _ := query("/sd?job=minio-buckets&bucket=prod-*"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown parameter, got %d", code)
+	}
+
+	config.SDAllowedParams = []string{"bucket_pattern"}
+	if _, err := client.ApplyConfig(config); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if code, _ := query("/sd?job=minio-buckets&exclude=*tmp*"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a parameter outside the allow-list, got %d", code)
+	}
+	if code, _ := query("/sd?job=minio-buckets&label.team=payments"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a label outside the allow-list, got %d", code)
+	}
+}
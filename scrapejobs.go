@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+// scrapeJobs lists the jobs served by this service, in the order they are generated
+var scrapeJobs = []string{"minio-server", "minio-buckets"}
+
+// JobConfig overrides the scrape settings of one job in the config file
+type JobConfig struct {
+	ScrapeInterval string `yaml:"scrape_interval" json:"scrape_interval,omitempty"`
+	ScrapeTimeout  string `yaml:"scrape_timeout" json:"scrape_timeout,omitempty"`
+	MetricsPath    string `yaml:"metrics_path" json:"metrics_path,omitempty"`
+}
+
+// JobSettings are the scrape settings of a job. In Config.Jobs, zero values are inherited
+// from the global settings.
+type JobSettings struct {
+	ScrapeInterval time.Duration
+	ScrapeTimeout  time.Duration
+	MetricsPath    string
+}
+
+// parseJobConfigs parses per-job overrides, naming their source (e.g. config file) in errors
+func parseJobConfigs(jobs map[string]JobConfig, source string) (map[string]JobSettings, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[string]JobSettings, len(jobs))
+	for job, jobConfig := range jobs {
+		settings := JobSettings{MetricsPath: jobConfig.MetricsPath}
+		for _, duration := range []struct {
+			key    string
+			value  string
+			target *time.Duration
+		}{
+			{"scrape_interval", jobConfig.ScrapeInterval, &settings.ScrapeInterval},
+			{"scrape_timeout", jobConfig.ScrapeTimeout, &settings.ScrapeTimeout},
+		} {
+			if duration.value == "" {
+				continue
+			}
+			value, err := time.ParseDuration(duration.value)
+			if err != nil {
+				return nil, fmt.Errorf("jobs.%s.%s (from %s): invalid duration %q", job, duration.key, source, duration.value)
+			}
+			if value <= 0 {
+				return nil, fmt.Errorf("jobs.%s.%s (from %s): must be positive, got %v", job, duration.key, source, value)
+			}
+			*duration.target = value
+		}
+		parsed[job] = settings
+	}
+	return parsed, nil
+}
+
+// defaultJobMetricsPath returns the metrics path of a job without override. Bucket targets
+// append the bucket name to it.
+func defaultJobMetricsPath(job, metricsPath string) string {
+	if job == "minio-buckets" {
+		return strings.TrimSuffix(metricsPath, "/") + "/bucket/api"
+	}
+	return metricsPath
+}
+
+// mergeJobSettings returns the per-job overrides with those of updates applied on top,
+// setting by setting. Neither map is modified.
+func mergeJobSettings(jobs, updates map[string]JobSettings) map[string]JobSettings {
+	merged := maps.Clone(jobs)
+	if merged == nil {
+		merged = make(map[string]JobSettings, len(updates))
+	}
+	for job, update := range updates {
+		settings := merged[job]
+		if update.ScrapeInterval > 0 {
+			settings.ScrapeInterval = update.ScrapeInterval
+		}
+		if update.ScrapeTimeout > 0 {
+			settings.ScrapeTimeout = update.ScrapeTimeout
+		}
+		if update.MetricsPath != "" {
+			settings.MetricsPath = update.MetricsPath
+		}
+		merged[job] = settings
+	}
+	return merged
+}
+
+// jobSettings returns the effective scrape settings of a job. A timeout that is not set
+// for the job is capped at the job's interval, as Prometheus does for its global default.
+func (c Config) jobSettings(job string) JobSettings {
+	overrides := c.Jobs[job]
+	settings := JobSettings{
+		ScrapeInterval: c.ScrapeInterval,
+		ScrapeTimeout:  c.ScrapeTimeout,
+		MetricsPath:    defaultJobMetricsPath(job, c.MetricsPath),
+	}
+	if overrides.ScrapeInterval > 0 {
+		settings.ScrapeInterval = overrides.ScrapeInterval
+	}
+	if overrides.ScrapeTimeout > 0 {
+		settings.ScrapeTimeout = overrides.ScrapeTimeout
+	} else {
+		settings.ScrapeTimeout = min(settings.ScrapeTimeout, settings.ScrapeInterval)
+	}
+	if overrides.MetricsPath != "" {
+		settings.MetricsPath = overrides.MetricsPath
+	}
+	return settings
+}
+
+// jobSettingSource returns the source of an effective job setting (scrape_interval,
+// scrape_timeout or metrics_path): the source of the jobs section if the job overrides it,
+// else the source of the global setting
+func (c Config) jobSettingSource(job, setting string) string {
+	overrides := c.Jobs[job]
+	overridden := map[string]bool{
+		"scrape_interval": overrides.ScrapeInterval > 0,
+		"scrape_timeout":  overrides.ScrapeTimeout > 0,
+		"metrics_path":    overrides.MetricsPath != "",
+	}
+	if overridden[setting] {
+		return c.Sources["jobs"]
+	}
+	return c.Sources[setting]
+}
+
+// formatDuration formats a duration with the h, m, s and ms units understood by Prometheus
+// as well as Go based agents, e.g. 1m30s. Prometheus rejects Go's fractional 1.5s.
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+	} {
+		if n := d / unit.size; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			d -= n * unit.size
+		}
+	}
+	if b.Len() == 0 {
+		return "0s"
+	}
+	return b.String()
+}
+
+// jobLabels returns the labels every target group of a job carries: the job name and the
+// __scheme__, __metrics_path__, __scrape_interval__ and __scrape_timeout__ labels that make
+// Prometheus scrape it with the job's settings, whatever the scrape config says
+func (m *MinIOClient) jobLabels(job string) map[string]string {
+	return m.cfg().jobLabels(job)
+}
+
+// jobLabels returns the labels every target group of a job carries with this configuration
+func (c Config) jobLabels(job string) map[string]string {
+	settings := c.jobSettings(job)
+	return map[string]string{
+		"__metrics_path__":    settings.MetricsPath,
+		"__scheme__":          c.scheme(),
+		"__scrape_interval__": formatDuration(settings.ScrapeInterval),
+		"__scrape_timeout__":  formatDuration(settings.ScrapeTimeout),
+		"job":                 job,
+		"sd_cluster":          c.ClusterName,
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{0, "0s"},
+		{15 * time.Second, "15s"},
+		{time.Minute, "1m"},
+		{90 * time.Second, "1m30s"},
+		{1500 * time.Millisecond, "1s500ms"},
+		{2*time.Hour + 5*time.Second, "2h5s"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.duration); got != tt.expected {
+			t.Errorf("formatDuration(%v) = %q, expected %q", tt.duration, got, tt.expected)
+		}
+	}
+}
+
+func TestJobSettings(t *testing.T) {
+	config := Config{
+		ScrapeInterval: time.Minute,
+		ScrapeTimeout:  20 * time.Second,
+		MetricsPath:    "/minio/metrics/v3",
+		Sources:        map[string]string{"jobs": SourceFile, "scrape_interval": SourceFile, "scrape_timeout": SourceDefault, "metrics_path": SourceDefault},
+		Jobs: map[string]JobSettings{
+			"minio-server": {ScrapeInterval: 10 * time.Second},
+		},
+	}
+
+	server := config.jobSettings("minio-server")
+	if server.ScrapeInterval != 10*time.Second || server.ScrapeTimeout != 10*time.Second || server.MetricsPath != "/minio/metrics/v3" {
+		t.Errorf("Expected the inherited timeout capped at the job interval, got %+v", server)
+	}
+	buckets := config.jobSettings("minio-buckets")
+	if buckets.ScrapeInterval != time.Minute || buckets.ScrapeTimeout != 20*time.Second || buckets.MetricsPath != "/minio/metrics/v3/bucket/api" {
+		t.Errorf("Expected the global settings, got %+v", buckets)
+	}
+
+	for key, expected := range map[string]string{
+		"jobs.minio-server.scrape_interval":  SourceFile,
+		"jobs.minio-server.scrape_timeout":   SourceDefault,
+		"jobs.minio-buckets.scrape_interval": SourceFile,
+		"jobs.minio-buckets.metrics_path":    SourceDefault,
+	} {
+		if got := config.settingSource(key); got != expected {
+			t.Errorf("Expected source %s for %s, got %s", expected, key, got)
+		}
+	}
+}
+
+func TestServiceDiscoveryScrapeLabels(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ScrapeInterval = 30 * time.Second
+		c.Jobs = map[string]JobSettings{
+			"minio-buckets": {ScrapeInterval: 5 * time.Minute, MetricsPath: "/minio/metrics/v3/bucket/replication"},
+		}
+	})
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "online"}},
+		Buckets:     []minio.BucketInfo{{Name: "prod-payments"}},
+	}
+
+	tests := []struct {
+		job                            string
+		metricsPath, interval, timeout string
+	}{
+		{"minio-server", "/minio/metrics/v3", "30s", "10s"},
+		{"minio-buckets", "/minio/metrics/v3/bucket/replication/prod-payments", "5m", "10s"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		client.handleServiceDiscovery(recorder, httptest.NewRequest(http.MethodGet, "/sd?job="+tt.job, nil))
+		var groups []ServiceDiscoveryResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &groups); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(groups) != 1 {
+			t.Fatalf("Job %s: expected 1 target group, got %d", tt.job, len(groups))
+		}
+		labels := groups[0].Labels
+		if labels["__metrics_path__"] != tt.metricsPath || labels["__scrape_interval__"] != tt.interval || labels["__scrape_timeout__"] != tt.timeout {
+			t.Errorf("Job %s: expected %s every %s with timeout %s, got %v", tt.job, tt.metricsPath, tt.interval, tt.timeout, labels)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// Query parameters selecting a shard of the target groups returned by /sd
+const (
+	ShardParam  = "shard"
+	ShardsParam = "shards"
+)
+
+// hashmod returns the shard of a value exactly as the Prometheus hashmod relabel action
+// computes it: the last 8 bytes of the MD5 sum, big endian, modulo the number of shards
+func hashmod(value string, modulus uint64) uint64 {
+	sum := md5.Sum([]byte(value))
+	return binary.BigEndian.Uint64(sum[8:]) % modulus
+}
+
+// targetLabelNames returns the sorted names of the labels target groups carry, including
+// __address__, which are the labels shards can be computed from
+func targetLabelNames(config Config) []string {
+	names := slices.Collect(maps.Keys(config.bucketLabels(minio.BucketInfo{})))
+	names = append(names, "__address__")
+	slices.Sort(names)
+	return names
+}
+
+// shardValue joins the values of the shard labels with the default relabel separator
+func shardValue(labels map[string]string, shardLabels []string) string {
+	values := make([]string, 0, len(shardLabels))
+	for _, name := range shardLabels {
+		values = append(values, labels[name])
+	}
+	return strings.Join(values, ";")
+}
+
+// splitByAddress turns target groups into one group per target when __address__ is a shard
+// label, since Prometheus hashes each target on its own
+func splitByAddress(groups []ServiceDiscoveryResponse, shardLabels []string) []ServiceDiscoveryResponse {
+	if !slices.Contains(shardLabels, "__address__") {
+		return groups
+	}
+	var split []ServiceDiscoveryResponse
+	for _, group := range groups {
+		for _, target := range group.Targets {
+			split = append(split, ServiceDiscoveryResponse{Targets: []string{target}, Labels: group.Labels})
+		}
+	}
+	return split
+}
+
+// groupLabels returns the labels of a target group as seen by relabeling, including __address__
+func groupLabels(group ServiceDiscoveryResponse) map[string]string {
+	if len(group.Targets) != 1 {
+		return group.Labels
+	}
+	labels := make(map[string]string, len(group.Labels)+1)
+	for name, value := range group.Labels {
+		labels[name] = value
+	}
+	labels["__address__"] = group.Targets[0]
+	return labels
+}
+
+// shardOf returns the shard a target group belongs to
+func shardOf(group ServiceDiscoveryResponse, shardLabels []string, shards int) int {
+	return int(hashmod(shardValue(groupLabels(group), shardLabels), uint64(shards)))
+}
+
+// parseShard parses the shard and shards query parameters; shards is 0 when sharding is not requested
+func parseShard(shardValue, shardsValue string) (shard, shards int, err error) {
+	if shardValue == "" && shardsValue == "" {
+		return 0, 0, nil
+	}
+	if shards, err = strconv.Atoi(shardsValue); err != nil || shards < 1 {
+		return 0, 0, fmt.Errorf("invalid %s %q", ShardsParam, shardsValue)
+	}
+	if shard, err = strconv.Atoi(shardValue); err != nil || shard < 0 || shard >= shards {
+		return 0, 0, fmt.Errorf("invalid %s %q, expected 0 to %d", ShardParam, shardValue, shards-1)
+	}
+	return shard, shards, nil
+}
+
+// ShardStats describes the target groups assigned to a shard
+type ShardStats struct {
+	Shard        int      `json:"shard"`
+	TargetGroups int      `json:"target_groups"`
+	Targets      int      `json:"targets"`
+	Keys         []string `json:"keys"` // Joined shard label values of the target groups
+}
+
+// ShardsResponse is the response of the /api/v1/shards endpoint
+type ShardsResponse struct {
+	Shards int                     `json:"shards"`
+	Labels []string                `json:"labels"`
+	Jobs   map[string][]ShardStats `json:"jobs"`
+}
+
+// shardStats computes how the target groups of a job are distributed across shards
+func shardStats(groups []ServiceDiscoveryResponse, shardLabels []string, shards int) []ShardStats {
+	stats := make([]ShardStats, shards)
+	for i := range stats {
+		stats[i] = ShardStats{Shard: i, Keys: []string{}}
+	}
+	for _, group := range splitByAddress(groups, shardLabels) {
+		shard := shardOf(group, shardLabels, shards)
+		stats[shard].TargetGroups++
+		stats[shard].Targets += len(group.Targets)
+		stats[shard].Keys = append(stats[shard].Keys, shardValue(groupLabels(group), shardLabels))
+	}
+	for i := range stats {
+		slices.Sort(stats[i].Keys)
+	}
+	return stats
+}
+
+// handleShards handles the /api/v1/shards endpoint showing the distribution of target
+// groups across ?shards=n shards for every job
+func (m *MinIOClient) handleShards(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, shards, err := parseShard("0", r.URL.Query().Get(ShardsParam))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	configs, err := m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to generate scrape configs: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "failed to generate scrape configs")
+		return
+	}
+
+	snapshot := m.Discover(ctx)
+	response := ShardsResponse{
+		Shards: shards,
+		Labels: m.cfg().ShardLabels,
+		Jobs:   make(map[string][]ShardStats, len(configs)),
+	}
+	for _, config := range configs {
+		groups := m.buildTargetGroups(config, snapshot, m.bucketFilter())
+		response.Jobs[config.JobName] = shardStats(groups, m.cfg().ShardLabels, shards)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestHashmod(t *testing.T) {
+	// Last 8 bytes of md5("foo") as a big endian integer, modulo 1000
+	if got := hashmod("foo", 1000); got != 696 {
+		t.Errorf("Expected hashmod 696, got %d", got)
+	}
+}
+
+func TestServiceDiscoverySharding(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ShardLabels = []string{"sd_bucket"}
+	})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000"}, {Endpoint: "node2:9000"}},
+	}
+	for i := range 20 {
+		snapshot.Buckets = append(snapshot.Buckets, minio.BucketInfo{Name: fmt.Sprintf("bucket-%02d", i), CreationDate: created})
+	}
+	client.snapshot = snapshot
+
+	// Every bucket is served by exactly one of the shards
+	seen := make(map[string]int)
+	for shard := range 4 {
+		recorder := httptest.NewRecorder()
+		client.handleServiceDiscovery(recorder, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/sd?job=minio-buckets&shard=%d&shards=4", shard), nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var groups []ServiceDiscoveryResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &groups); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, group := range groups {
+			bucket := group.Labels["sd_bucket"]
+			if int(hashmod(bucket, 4)) != shard {
+				t.Errorf("Bucket %s served by shard %d, hashmod says %d", bucket, shard, hashmod(bucket, 4))
+			}
+			seen[bucket]++
+		}
+	}
+	if len(seen) != 20 {
+		t.Errorf("Expected all 20 buckets across shards, got %d", len(seen))
+	}
+	for bucket, count := range seen {
+		if count != 1 {
+			t.Errorf("Bucket %s served by %d shards", bucket, count)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	client.handleServiceDiscovery(recorder, httptest.NewRequest(http.MethodGet, "/sd?job=minio-buckets&shard=4&shards=4", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an out of range shard, got %d", recorder.Code)
+	}
+}
+
+func TestShardValueWithCluster(t *testing.T) {
+	client := newTestMinIOClient(t)
+	labels := client.bucketLabels(minio.BucketInfo{Name: "payments"})
+	if got := shardValue(labels, []string{"sd_cluster", "sd_bucket"}); got != "test;payments" {
+		t.Errorf("Expected the cluster and bucket to be hashed, got %q", got)
+	}
+}
+
+func TestHandleShards(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.ShardLabels = []string{"sd_bucket"}
+	})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000"}, {Endpoint: "node2:9000"}},
+		Buckets: []minio.BucketInfo{
+			{Name: "alpha", CreationDate: created},
+			{Name: "beta", CreationDate: created},
+			{Name: "gamma", CreationDate: created},
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	client.handleShards(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/shards?shards=2", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response ShardsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	stats := response.Jobs["minio-buckets"]
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 shards, got %d", len(stats))
+	}
+	var keys []string
+	for _, shard := range stats {
+		if shard.Targets != 2*shard.TargetGroups {
+			t.Errorf("Shard %d: expected 2 targets per group, got %d for %d groups", shard.Shard, shard.Targets, shard.TargetGroups)
+		}
+		keys = append(keys, shard.Keys...)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"alpha", "beta", "gamma"}) {
+		t.Errorf("Expected every bucket in exactly one shard, got %v", keys)
+	}
+
+	recorder = httptest.NewRecorder()
+	client.handleShards(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/shards", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without shards, got %d", recorder.Code)
+	}
+}
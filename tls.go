@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// WebConfig mirrors the subset of Prometheus's web.config.file used by this service,
+// so the same file and tooling can be shared with Prometheus and exporters
+type WebConfig struct {
+	TLSServerConfig *TLSServerConfig  `yaml:"tls_server_config"`
+	BasicAuthUsers  map[string]string `yaml:"basic_auth_users"`
+
+	// BasicAuthUserFiles maps user names to files each containing a bcrypt password hash
+	BasicAuthUserFiles map[string]string `yaml:"basic_auth_user_files"`
+}
+
+// TLSServerConfig holds the HTTPS listener settings
+type TLSServerConfig struct {
+	CertFile         string   `yaml:"cert_file"`
+	KeyFile          string   `yaml:"key_file"`
+	ClientCAFile     string   `yaml:"client_ca_file"`
+	ClientAuthType   string   `yaml:"client_auth_type"`
+	MinVersion       string   `yaml:"min_version"`
+	MaxVersion       string   `yaml:"max_version"`
+	CipherSuites     []string `yaml:"cipher_suites"`
+	CurvePreferences []string `yaml:"curve_preferences"`
+}
+
+// loadWebConfig loads a Prometheus style web configuration file. Like the config file, it
+// is decoded strictly, so a misspelled key cannot silently leave TLS or auth turned off.
+func loadWebConfig(filename string) (*WebConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web config file %s: %w", filename, err)
+	}
+
+	var config WebConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse web config file %s: %w", filename, err)
+	}
+	return &config, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"CurveP256": tls.CurveP256,
+	"CurveP384": tls.CurveP384,
+	"CurveP521": tls.CurveP521,
+	"X25519":    tls.X25519,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// verifiesClientCerts reports whether the listener verifies client certificates against
+// the client CA, which the client_cert auth method relies on
+func (c *TLSServerConfig) verifiesClientCerts() bool {
+	if c == nil || c.ClientCAFile == "" {
+		return false
+	}
+	switch c.ClientAuthType {
+	case "", "VerifyClientCertIfGiven", "RequireAndVerifyClientCert":
+		return true
+	}
+	return false
+}
+
+// NewTLSConfig builds the server TLS configuration. Certificate, key and client CA
+// files are re-read whenever they change on disk, so rotated certificates are
+// picked up without a restart.
+func NewTLSConfig(c *TLSServerConfig) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("tls_server_config requires both cert_file and key_file")
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS min_version %q", c.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if c.MaxVersion != "" {
+		version, ok := tlsVersions[c.MaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS max_version %q", c.MaxVersion)
+		}
+		config.MaxVersion = version
+	}
+
+	if len(c.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range c.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	for _, name := range c.CurvePreferences {
+		curve, ok := tlsCurves[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS curve %q", name)
+		}
+		config.CurvePreferences = append(config.CurvePreferences, curve)
+	}
+
+	switch {
+	case c.ClientAuthType != "":
+		authType, ok := clientAuthTypes[c.ClientAuthType]
+		if !ok {
+			return nil, fmt.Errorf("unknown client_auth_type %q", c.ClientAuthType)
+		}
+		config.ClientAuth = authType
+	case c.ClientCAFile != "":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.ClientCAFile == "" && (config.ClientAuth == tls.VerifyClientCertIfGiven || config.ClientAuth == tls.RequireAndVerifyClientCert) {
+		return nil, fmt.Errorf("client_auth_type %s requires client_ca_file", c.ClientAuthType)
+	}
+
+	reloader := &certReloader{certFile: c.CertFile, keyFile: c.KeyFile, caFile: c.ClientCAFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	config.GetCertificate = reloader.GetCertificate
+	if c.ClientCAFile != "" {
+		// A per-connection config picks up the current client CA pool
+		base := config.Clone()
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			connConfig := base.Clone()
+			connConfig.ClientCAs = reloader.ClientCAs()
+			return connConfig, nil
+		}
+	}
+
+	return config, nil
+}
+
+// certReloader keeps the server certificate and client CA pool in sync with the files on disk
+type certReloader struct {
+	certFile, keyFile, caFile string
+
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+}
+
+// GetCertificate implements tls.Config.GetCertificate, reloading changed files first
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if r.changed() {
+		if err := r.reload(); err != nil {
+			logrus.Errorf("Failed to reload TLS certificate, keeping the previous one: %v", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+// ClientCAs returns the current client CA pool, reloading it first if it changed
+func (r *certReloader) ClientCAs() *x509.CertPool {
+	if r.changed() {
+		if err := r.reload(); err != nil {
+			logrus.Errorf("Failed to reload TLS client CA, keeping the previous one: %v", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.caPool
+}
+
+// changed reports whether any of the files was modified since the last load
+func (r *certReloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload reads the certificate, key and client CA files
+func (r *certReloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate %s: %w", r.certFile, err)
+	}
+
+	var caPool *x509.CertPool
+	if r.caFile != "" {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file %s: %w", r.caFile, err)
+		}
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in client CA file %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil {
+		logrus.Infof("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert = &cert
+	r.caPool = caPool
+	r.modTimes = modTimes
+	return nil
+}
+
+// files returns the files watched for changes
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// applyWebConfig loads the web config file of a configuration, if any, and merges its
+// basic auth users into the auth settings. It returns the TLS settings of the listener,
+// or nil when TLS is not enabled.
+func applyWebConfig(config *Config) (*tls.Config, error) {
+	if config.WebConfigFile == "" {
+		return nil, nil
+	}
+	webConfig, err := loadWebConfig(config.WebConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if webConfig.TLSServerConfig != nil {
+		tlsConfig, err = NewTLSConfig(webConfig.TLSServerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+		config.TLSEnabled = true
+		config.TLSServer = webConfig.TLSServerConfig
+		server := webConfig.TLSServerConfig
+		for _, file := range []string{server.CertFile, server.KeyFile, server.ClientCAFile} {
+			if file != "" {
+				config.WebConfigFiles = append(config.WebConfigFiles, file)
+			}
+		}
+	}
+	for _, user := range slices.Sorted(maps.Keys(webConfig.BasicAuthUserFiles)) {
+		config.WebConfigFiles = append(config.WebConfigFiles, webConfig.BasicAuthUserFiles[user])
+	}
+	users, err := mergeBasicAuthUserFiles(webConfig.BasicAuthUsers, webConfig.BasicAuthUserFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) > 0 {
+		merged := maps.Clone(config.Auth.BasicAuthUsers)
+		if merged == nil {
+			merged = make(map[string]string, len(users))
+		}
+		for _, user := range slices.Sorted(maps.Keys(users)) {
+			if _, ok := merged[user]; ok {
+				return nil, fmt.Errorf("basic auth user %s is set in both the web config file and the auth section", user)
+			}
+			merged[user] = users[user]
+		}
+		config.Auth.BasicAuthUsers = merged
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate and key with the given serial number
+func writeTestCertificate(t *testing.T, certFile, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set file times: %v", err)
+		}
+	}
+}
+
+func TestTLSCertificateHotReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	now := time.Now()
+	writeTestCertificate(t, certFile, keyFile, 1, now.Add(-time.Minute))
+
+	tlsConfig, err := NewTLSConfig(&TLSServerConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "TLS12"})
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: tlsConfig,
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+	url := "https://" + listener.Addr().String()
+
+	servedSerial := func() int64 {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if serial := servedSerial(); serial != 1 {
+		t.Fatalf("Expected certificate serial 1, got %d", serial)
+	}
+
+	// Rotate the certificate on disk
+	writeTestCertificate(t, certFile, keyFile, 2, now)
+	if serial := servedSerial(); serial != 2 {
+		t.Errorf("Expected rotated certificate serial 2, got %d", serial)
+	}
+}
+
+func TestNewTLSConfigRejectsInvalidSettings(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certFile, keyFile, 1, time.Now())
+
+	tests := []struct {
+		name   string
+		config TLSServerConfig
+	}{
+		{"missing key", TLSServerConfig{CertFile: certFile}},
+		{"unknown min version", TLSServerConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "TLS14"}},
+		{"unknown cipher suite", TLSServerConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_FAKE"}}},
+		{"verify without CA", TLSServerConfig{CertFile: certFile, KeyFile: keyFile, ClientAuthType: "RequireAndVerifyClientCert"}},
+		{"missing certificate file", TLSServerConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTLSConfig(&tt.config); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// uiTemplates holds the server-rendered pages of the web UI
+var uiTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"labelPairs": labelPairs,
+	"age":        formatAge,
+	"timestamp":  formatTimestamp,
+}).ParseFS(templateFS, "templates/*.html"))
+
+// ConfigSetting is a single effective configuration value, keyed by its config file name
+type ConfigSetting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"` // Value is masked
+	Source string `json:"source,omitempty"` // default, file, profile, env or flag
+}
+
+// ClusterSummary summarizes the discovery state of a cluster
+type ClusterSummary struct {
+	Name               string        `json:"name"`
+	Endpoint           string        `json:"endpoint"`
+	NodesOnline        int           `json:"nodes_online"`
+	NodesTotal         int           `json:"nodes_total"`
+	Buckets            int           `json:"buckets"`
+	BucketsIncluded    int           `json:"buckets_included"`
+	UpdatedAt          time.Time     `json:"updated_at,omitzero"`
+	RefreshedAt        time.Time     `json:"refreshed_at,omitzero"`
+	SnapshotAgeSeconds *float64      `json:"snapshot_age_seconds"` // Null until the first successful refresh
+	LastError          string        `json:"last_error,omitempty"`
+	CircuitBreaker     BreakerStatus `json:"circuit_breaker"`
+}
+
+// UIJob holds the target groups currently served for a job
+type UIJob struct {
+	Name   string
+	Groups []ServiceDiscoveryResponse
+}
+
+// UIPage is the data rendered by the web UI
+type UIPage struct {
+	Version        string
+	Now            time.Time
+	RefreshSeconds int
+	Clusters       []ClusterSummary
+	Nodes          []NodeInventoryEntry
+	Buckets        []BucketInventoryEntry
+	Jobs           []UIJob
+	Changes        []ChangeEvent
+	Config         []ConfigSetting
+	Reload         ReloadStatus
+}
+
+// labelPairs returns labels as sorted name=value pairs
+func labelPairs(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	slices.Sort(pairs)
+	return pairs
+}
+
+// formatAge renders the time elapsed since t, or "never" for the zero time
+func formatAge(now, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return now.Sub(t).Truncate(time.Second).String() + " ago"
+}
+
+// formatTimestamp renders t in RFC 3339, or "-" for the zero time
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// effectiveConfig lists the configuration in use with the source of each setting, with credentials masked
+func effectiveConfig(config Config) []ConfigSetting {
+	list := func(values []string) string { return strings.Join(values, ",") }
+	settings := []ConfigSetting{
+		{Key: "profile", Value: config.Profile},
+		{Key: "minio_endpoint", Value: config.MinIOEndpoint},
+		{Key: "minio_access_key", Value: maskSensitive(config.MinIOAccessKey), Secret: true},
+		{Key: "minio_secret_key", Value: maskSensitive(config.MinIOSecretKey), Secret: true},
+		{Key: "minio_access_key_file", Value: config.MinIOAccessKeyFile},
+		{Key: "minio_secret_key_file", Value: config.MinIOSecretKeyFile},
+		{Key: "minio_use_ssl", Value: strconv.FormatBool(config.MinIOUseSSL)},
+		{Key: "listen_addr", Value: config.ListenAddr},
+		{Key: "web_config_file", Value: config.WebConfigFile},
+		{Key: "shutdown_timeout", Value: config.ShutdownTimeout.String()},
+		{Key: "readiness_max_age", Value: config.ReadinessMaxAge.String()},
+		{Key: "scrape_interval", Value: config.ScrapeInterval.String()},
+		{Key: "scrape_timeout", Value: config.ScrapeTimeout.String()},
+		{Key: "metrics_path", Value: config.MetricsPath},
+		{Key: "bucket_pattern", Value: config.BucketPattern},
+		{Key: "bucket_exclude_pattern", Value: config.BucketExcludePattern},
+		{Key: "cluster_name", Value: config.ClusterName},
+		{Key: "bucket_owner_tag", Value: config.BucketOwnerTag},
+		{Key: "sd_allowed_params", Value: list(config.SDAllowedParams)},
+		{Key: "shard_labels", Value: list(config.ShardLabels)},
+		{Key: "external_url", Value: config.ExternalURL},
+		{Key: "prometheus_sd_token_file", Value: config.PrometheusSDTokenFile},
+		{Key: "prometheus_minio_token_file", Value: config.PrometheusMinIOTokenFile},
+		{Key: "vmagent_series_limit", Value: strconv.Itoa(config.VMAgentSeriesLimit)},
+		{Key: "telegraf_minio_token_file", Value: config.TelegrafMinIOTokenFile},
+		{Key: "alloy_forward_to", Value: config.AlloyForwardTo},
+		{Key: "file_sd_dir", Value: config.FileSDDir},
+		{Key: "file_sd_format", Value: config.FileSDFormat},
+		{Key: "consul_listen_addr", Value: config.ConsulListenAddr},
+		{Key: "dns_listen_addr", Value: config.DNSListenAddr},
+		{Key: "dns_domain", Value: config.DNSDomain},
+		{Key: "server_info_timeout", Value: config.ServerInfoTimeout.String()},
+		{Key: "list_buckets_timeout", Value: config.ListBucketsTimeout.String()},
+		{Key: "enrichment_timeout", Value: config.EnrichmentTimeout.String()},
+		{Key: "bucket_enrichment", Value: strconv.FormatBool(config.BucketEnrichment)},
+		{Key: "enrichment_workers", Value: strconv.Itoa(config.EnrichmentWorkers)},
+		{Key: "enrichment_deadline", Value: config.EnrichmentDeadline.String()},
+		{Key: "breaker_failure_threshold", Value: strconv.Itoa(config.BreakerFailureThreshold)},
+		{Key: "backoff_initial", Value: config.BackoffInitial.String()},
+		{Key: "backoff_max", Value: config.BackoffMax.String()},
+		{Key: "log_level", Value: config.LogLevel},
+	}
+
+	// Only user names and counts of the authentication credentials are shown
+	users := make([]string, 0, len(config.Auth.BasicAuthUsers))
+	for user := range config.Auth.BasicAuthUsers {
+		users = append(users, user)
+	}
+	slices.Sort(users)
+	routes := make(map[string]string, len(config.Auth.RoutePolicies))
+	for route, methods := range config.Auth.RoutePolicies {
+		routes[route] = list(methods)
+	}
+	settings = append(settings,
+		ConfigSetting{Key: "auth.basic_auth_users", Value: list(users), Secret: len(users) > 0},
+		ConfigSetting{Key: "auth.bearer_token_files", Value: list(config.Auth.BearerTokenFiles)},
+		ConfigSetting{Key: "auth.bearer_token_sha256", Value: fmt.Sprintf("%d hash(es)", len(config.Auth.BearerTokenHashes)), Secret: len(config.Auth.BearerTokenHashes) > 0},
+		ConfigSetting{Key: "auth.client_cert_allowed_cns", Value: list(config.Auth.ClientCertCNs)},
+		ConfigSetting{Key: "auth.default_policy", Value: list(config.Auth.DefaultPolicy)},
+		ConfigSetting{Key: "auth.route_policies", Value: strings.Join(labelPairs(routes), " ")},
+	)
+	// Every job is listed with its effective settings, so the rows are the same for any config
+	for _, job := range scrapeJobs {
+		jobSettings := config.jobSettings(job)
+		settings = append(settings,
+			ConfigSetting{Key: "jobs." + job + ".scrape_interval", Value: jobSettings.ScrapeInterval.String()},
+			ConfigSetting{Key: "jobs." + job + ".scrape_timeout", Value: jobSettings.ScrapeTimeout.String()},
+			ConfigSetting{Key: "jobs." + job + ".metrics_path", Value: jobSettings.MetricsPath},
+		)
+	}
+	for i := range settings {
+		settings[i].Source = config.settingSource(settings[i].Key)
+	}
+	return settings
+}
+
+// clusterSummary summarizes the discovery state of the cluster of a snapshot
+func (m *MinIOClient) clusterSummary(snapshot *Snapshot, now time.Time) ClusterSummary {
+	summary := ClusterSummary{
+		Name:            snapshot.Cluster,
+		Endpoint:        m.cfg().MinIOEndpoint,
+		NodesTotal:      len(snapshot.Nodes),
+		Buckets:         len(snapshot.Buckets),
+		BucketsIncluded: len(m.bucketFilter().Apply(snapshot.Buckets)),
+		UpdatedAt:       snapshot.UpdatedAt,
+		RefreshedAt:     snapshot.RefreshedAt,
+		LastError:       snapshot.LastError,
+		CircuitBreaker:  m.breaker.Status(),
+	}
+	for _, node := range snapshot.Nodes {
+		if node.State == "online" {
+			summary.NodesOnline++
+		}
+	}
+	if !snapshot.UpdatedAt.IsZero() {
+		age := now.Sub(snapshot.UpdatedAt).Seconds()
+		summary.SnapshotAgeSeconds = &age
+	}
+	return summary
+}
+
+// buildUIPage gathers the current discovery state for the web UI without recording metrics
+func (m *MinIOClient) buildUIPage(configs []ScrapeConfig, snapshot *Snapshot, now time.Time) UIPage {
+	filter := m.bucketFilter()
+	page := UIPage{
+		Version:        version,
+		Now:            now,
+		RefreshSeconds: max(int(m.cfg().ScrapeInterval.Seconds()), 5),
+		Nodes:          nodeInventory(snapshot),
+		Buckets:        m.bucketInventory(snapshot, filter),
+		Changes:        m.changes.Recent(),
+		Clusters:       []ClusterSummary{m.clusterSummary(snapshot, now)},
+		Config:         effectiveConfig(*m.cfg()),
+		Reload:         m.LastReload(),
+	}
+	for _, config := range configs {
+		page.Jobs = append(page.Jobs, UIJob{
+			Name:   config.JobName,
+			Groups: m.buildTargetGroups(config, snapshot, filter),
+		})
+	}
+	return page
+}
+
+// handleUI handles the / endpoint, rendering the current discovery state as HTML
+func (m *MinIOClient) handleUI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	configs, err := m.GenerateScrapeConfigs(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to generate scrape configs: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	page := m.buildUIPage(configs, m.Discover(ctx), time.Now())
+
+	var body strings.Builder
+	if err := uiTemplates.ExecuteTemplate(&body, "index.html", page); err != nil {
+		logrus.Errorf("Failed to render web UI: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, body.String())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestHandleUI(t *testing.T) {
+	client := newTestMinIOClient(t, func(c *Config) {
+		c.BucketExcludePattern = "*tmp*"
+		c.MinIOSecretKey = "supersecretkey"
+		c.Auth.BasicAuthUsers = map[string]string{"admin": "$2y$10$mDwo.lAisC94iLAyP81MCesa29IzH37oigHC/42V2pdJlUprsJPze"}
+	})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.snapshot = &Snapshot{
+		Cluster:     "test",
+		RefreshedAt: time.Now(),
+		UpdatedAt:   time.Now(),
+		Nodes:       []NodeInfo{{Endpoint: "node1:9000", State: "online"}, {Endpoint: "node2:9000", State: "offline"}},
+		Buckets: []minio.BucketInfo{
+			{Name: "data", CreationDate: created},
+			{Name: "scratch-tmp", CreationDate: created},
+			{Name: "<script>", CreationDate: created},
+		},
+	}
+	client.changes.Record(ChangeEvent{Time: created, Cluster: "test", Kind: ChangeBucketAdded, Subject: "data"})
+
+	recorder := httptest.NewRecorder()
+	client.handleUI(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("Expected HTML, got %s", contentType)
+	}
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		"node2:9000",
+		`name matches bucket_exclude_pattern &#34;*tmp*&#34;`,
+		`<span class="label">sd_bucket=data</span>`,
+		"bucket_added",
+		"su***ey",
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected page to contain %s", expected)
+		}
+	}
+	for _, secret := range []string{"supersecretkey", "mDwo.lAisC94iLAyP81MCes", "<script>"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("Page must not contain %s", secret)
+		}
+	}
+}